@@ -10,11 +10,12 @@ import (
 	"github.com/GoWebProd/msgp/msgp"
 )
 
-func scramble(encodedSalt, pass string) (scramble []byte, err error) {
+// Scramble returns chap-sha1 scramble of password with salt decoded
+// from greeting, salt must have at least 20 bytes.
+func Scramble(salt []byte, pass string) []byte {
 	/* ==================================================================
 		According to: http://tarantool.org/doc/dev_guide/box-protocol.html
 
-		salt = base64_decode(encodedSalt);
 		step1 = sha1(password);
 		step2 = sha1(step1);
 		step3 = sha1(salt, step2);
@@ -24,18 +25,28 @@ func scramble(encodedSalt, pass string) (scramble []byte, err error) {
 	===================================================================== */
 	scrambleSize := sha1.Size // == 20
 
-	salt, err := base64.StdEncoding.DecodeString(encodedSalt)
-	if err != nil {
-		return
-	}
 	step1 := sha1.Sum([]byte(pass))
 	step2 := sha1.Sum(step1[0:])
-	hash := sha1.New() // may be create it once per connection ?
+	hash := sha1.New()
 	hash.Write(salt[0:scrambleSize])
 	hash.Write(step2[0:])
 	step3 := hash.Sum(nil)
 
-	return xor(step1[0:], step3[0:], scrambleSize), nil
+	return xor(step1[0:], step3[0:], scrambleSize)
+}
+
+// scramble returns scramble of password with base64 encoded salt of greeting.
+func scramble(encodedSalt, pass string) (scramble []byte, err error) {
+	salt, err := base64.StdEncoding.DecodeString(encodedSalt)
+	if err != nil {
+		return
+	}
+
+	if len(salt) < sha1.Size {
+		return nil, errors.New("auth: salt is too short")
+	}
+
+	return Scramble(salt, pass), nil
 }
 
 func xor(left, right []byte, size int) []byte {
@@ -104,7 +115,9 @@ func (conn *Connection) writeAuthRequest(w *bufio.Writer, scramble []byte) error
 }
 
 func (conn *Connection) readAuthResponse(r io.Reader) error {
-	respBytes, err := conn.read(r)
+	var lenBuf [5]byte
+
+	respBytes, err := conn.read(r, lenBuf[:])
 	if err != nil {
 		return errors.New("auth: read error " + err.Error())
 	}
@@ -124,5 +137,7 @@ func (conn *Connection) readAuthResponse(r io.Reader) error {
 		return Error{resp.Code, resp.Error}
 	}
 
+	resp.Release()
+
 	return nil
 }
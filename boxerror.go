@@ -0,0 +1,177 @@
+package tarantool
+
+import (
+	"fmt"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ErrorExtension is msgpack extension type of tarantool box.error.
+	// msgp reserves it for complex64, so it is decoded with Decode only.
+	ErrorExtension = 3
+	// CompressionExtension is msgpack extension type of compressed data.
+	// It has no builtin decoder and is decoded as *msgp.RawExtension
+	// unless decoder is registered with RegisterExt.
+	CompressionExtension = 5
+)
+
+// MP_ERROR keys
+const (
+	errorStack = 0x00
+)
+
+const (
+	errorType = iota
+	errorFile
+	errorLine
+	errorMessage
+	errorErrno
+	errorCode
+	errorFields
+)
+
+// BoxError is tarantool box.error returned as value, ie by call or eval.
+// Errors of stack are linked with Prev, starting from the last raised one.
+type BoxError struct {
+	Type   string
+	File   string
+	Line   uint64
+	Msg    string
+	Errno  uint64
+	Code   uint64
+	Fields map[string]interface{}
+	Prev   *BoxError
+}
+
+func (e *BoxError) Error() string {
+	return fmt.Sprintf("%s (%s, 0x%x)", e.Msg, e.Type, e.Code)
+}
+
+// Unwrap returns the previous error of stack.
+func (e *BoxError) Unwrap() error {
+	if e.Prev == nil {
+		return nil
+	}
+
+	return e.Prev
+}
+
+// UnmarshalBinary decodes data of MP_ERROR extension.
+func (e *BoxError) UnmarshalBinary(b []byte) error {
+	n, remain, err := msgp.ReadMapHeaderBytes(b)
+	if err != nil {
+		return errors.Wrap(err, "can't decode box error")
+	}
+
+	var stack []*BoxError
+
+	for i := uint32(0); i < n; i++ {
+		var key uint64
+
+		if key, remain, err = msgp.ReadUint64Bytes(remain); err != nil {
+			return errors.Wrap(err, "can't decode box error key")
+		}
+
+		if key != errorStack {
+			if remain, err = msgp.Skip(remain); err != nil {
+				return errors.Wrap(err, "can't decode box error")
+			}
+
+			continue
+		}
+
+		var size uint32
+
+		if size, remain, err = msgp.ReadArrayHeaderBytes(remain); err != nil {
+			return errors.Wrap(err, "can't decode box error stack")
+		}
+
+		stack = make([]*BoxError, size)
+
+		for j := range stack {
+			stack[j] = new(BoxError)
+
+			if remain, err = stack[j].unmarshalEntry(remain); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(stack) == 0 {
+		return errors.New("can't decode box error: empty stack")
+	}
+
+	for j := 0; j < len(stack)-1; j++ {
+		stack[j].Prev = stack[j+1]
+	}
+
+	*e = *stack[0]
+
+	return nil
+}
+
+func (e *BoxError) unmarshalEntry(b []byte) ([]byte, error) {
+	n, remain, err := msgp.ReadMapHeaderBytes(b)
+	if err != nil {
+		return b, errors.Wrap(err, "can't decode box error entry")
+	}
+
+	for i := uint32(0); i < n; i++ {
+		var key uint64
+
+		if key, remain, err = msgp.ReadUint64Bytes(remain); err != nil {
+			return b, errors.Wrap(err, "can't decode box error entry key")
+		}
+
+		switch key {
+		case errorType:
+			e.Type, remain, err = msgp.ReadStringBytes(remain)
+		case errorFile:
+			e.File, remain, err = msgp.ReadStringBytes(remain)
+		case errorLine:
+			e.Line, remain, err = msgp.ReadUint64Bytes(remain)
+		case errorMessage:
+			e.Msg, remain, err = msgp.ReadStringBytes(remain)
+		case errorErrno:
+			e.Errno, remain, err = msgp.ReadUint64Bytes(remain)
+		case errorCode:
+			e.Code, remain, err = msgp.ReadUint64Bytes(remain)
+		case errorFields:
+			var v interface{}
+
+			if v, remain, err = Decode(remain); err == nil {
+				e.Fields, err = stringMap(v)
+			}
+		default:
+			remain, err = msgp.Skip(remain)
+		}
+
+		if err != nil {
+			return b, errors.Wrapf(err, "can't decode box error field %d", key)
+		}
+	}
+
+	return remain, nil
+}
+
+func stringMap(v interface{}) (map[string]interface{}, error) {
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.Errorf("map expected, got %T", v)
+	}
+
+	res := make(map[string]interface{}, len(m))
+
+	for k, v := range m {
+		s, ok := k.(string)
+		if !ok {
+			return nil, errors.Errorf("string key expected, got %T", k)
+		}
+
+		res[s] = v
+	}
+
+	return res, nil
+}
@@ -52,6 +52,21 @@ func (k StringKey) Msgsize() int {
 	return 1 + msgp.StringSize(len(k.S))
 }
 
+// UUIDKey is utility type for passing uuid key to Select*, Update* and Delete*
+// It serializes to array with single uuid element.
+type UUIDKey struct {
+	U UUID
+}
+
+func (k UUIDKey) EncodeMsg(enc *msgp.Writer) error {
+	enc.WriteArrayHeader(1)
+	return enc.WriteExtension(&k.U)
+}
+
+func (k UUIDKey) Msgsize() int {
+	return 1 + k.U.Msgsize()
+}
+
 // Op - is update operation
 type Op struct {
 	Op    string
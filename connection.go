@@ -2,11 +2,13 @@ package tarantool
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,23 +18,40 @@ import (
 )
 
 type Connection struct {
-	addr  string
+	addr string
+	// c is the first socket, it identifies current physical connection
 	c     net.Conn
 	mutex sync.Mutex
-	// Schema contains schema loaded on connection.
-	Schema    *Schema
+	// Schema contains schema loaded by Connect. It is not set on lazy
+	// connection, which loads schema in background, use GetSchema.
+	Schema *Schema
+	// schema is *Schema published for concurrent readers
+	schema    atomic.Value
 	requestId uint32
 	// Greeting contains first message sent by tarantool
 	Greeting *Greeting
 
-	shard []connShard
-	queue chan *Future
-
-	lenBuf [5]byte
+	shard    []connShard
+	sockets  []socket
+	inflight chan struct{}
 
 	control chan struct{}
 	opts    Opts
+	invoker Invoker
 	state   uint32
+	// draining is a code of error for new requests while
+	// connection waits for in-flight ones to be completed
+	draining uint32
+
+	stats connStats
+
+	offline offlineQueue
+
+	// ready is closed when lazy connection is established
+	// and schema is loaded
+	ready chan struct{}
+	// initializing is set while lazy connection loads schema
+	initializing uint32
 }
 
 // Opts is a way to configure Connection
@@ -61,6 +80,54 @@ type Opts struct {
 	// SkipSchema disables schema loading. Without disabling schema loading,
 	// there is no way to create Connection for currently not accessible tarantool.
 	SkipSchema bool
+	// MaxInFlight limits amount of requests sent but not completed yet.
+	// By default there is no limit.
+	MaxInFlight uint32
+	// RLimitAction is an action performed when MaxInFlight is reached:
+	// RLimitDrop fails request with ErrRateLimited, RLimitWait (default)
+	// waits until one of in-flight requests is completed.
+	RLimitAction uint32
+	// Interceptors wrap every request performed by Connection methods,
+	// Do and DoAsync. The first one is the outermost.
+	// Async methods run interceptors in a separate goroutine.
+	Interceptors []Interceptor
+	// Retry is a policy of retrying idempotent requests performed by
+	// Connection methods, Do and DoAsync. Interceptors see every request
+	// once, retries are performed inside of them.
+	// By default requests are not retried.
+	Retry RetryPolicy
+	// PingInterval is a pause between health checks of connection.
+	// By default it is Timeout/3, or 1s if Timeout is not set.
+	// Negative value disables health checks.
+	PingInterval time.Duration
+	// HealthCheck is performed instead of ping, ie to check box.info.status.
+	// ctx expires after Timeout, or after PingInterval if Timeout is not set.
+	HealthCheck func(ctx context.Context, conn *Connection) error
+	// PingFailures is a number of consecutive failed health checks after
+	// which connection is reestablished, or closed if Reconnect is not set.
+	// By default failed health checks are only logged.
+	PingFailures uint
+	// OfflineQueue is a maximum number of requests kept while connection
+	// is not ready, they are sent when it is reestablished. By default
+	// such requests fail immediately with ErrConnectionNotReady.
+	OfflineQueue uint32
+	// OfflineTimeout is a maximum time request is kept in OfflineQueue,
+	// then it fails with ErrTimeouted. By default it is Timeout, or 1s.
+	OfflineTimeout time.Duration
+	// Lazy makes Connect return immediately, connection is established
+	// and schema is loaded in background. Requests wait for it until their
+	// context is done, or for Timeout if context has no deadline.
+	// Reconnect is 1s by default for lazy connection.
+	Lazy bool
+	// Sockets is an amount of physical connections to tarantool, every one
+	// has its own writer and reader. Requests are spread over them by
+	// request id, reconnect closes and reestablishes all of them.
+	// By default it is 1.
+	Sockets uint32
+	// Logger receives connection lifecycle events, protocol errors
+	// and responses to unknown requests. *slog.Logger could be used.
+	// By default nothing is logged.
+	Logger Logger
 }
 
 // Connect creates and configures new Connection
@@ -88,6 +155,9 @@ type Opts struct {
 //
 // - If opts.Reconnect is non-zero, then error will be returned only if authorization// fails. But if Tarantool is not reachable, then it will attempt to reconnect later
 // and will not end attempts on authorization failures.
+//
+// - If opts.Lazy is set, then error is never returned, connection is established
+// in background.
 func Connect(addr string, opts Opts) (*Connection, error) {
 	conn := &Connection{
 		addr:      addr,
@@ -97,6 +167,10 @@ func Connect(addr string, opts Opts) (*Connection, error) {
 		opts:      opts,
 	}
 
+	if conn.opts.Logger == nil {
+		conn.opts.Logger = nopLogger{}
+	}
+
 	maxprocs := uint32(runtime.GOMAXPROCS(-1))
 	if conn.opts.Concurrency == 0 || conn.opts.Concurrency > maxprocs*128 {
 		conn.opts.Concurrency = maxprocs * 4
@@ -110,8 +184,22 @@ func Connect(addr string, opts Opts) (*Connection, error) {
 		conn.opts.Concurrency = c + 1
 	}
 
+	conn.buildInvoker()
+
 	conn.shard = make([]connShard, conn.opts.Concurrency)
-	conn.queue = make(chan *Future, conn.opts.Concurrency*2)
+	if conn.opts.Sockets == 0 {
+		conn.opts.Sockets = 1
+	}
+
+	conn.sockets = make([]socket, conn.opts.Sockets)
+
+	for i := range conn.sockets {
+		conn.sockets[i].queue = make(chan *Future, conn.opts.Concurrency*2)
+	}
+
+	if conn.opts.MaxInFlight > 0 {
+		conn.inflight = make(chan struct{}, conn.opts.MaxInFlight)
+	}
 
 	for i := range conn.shard {
 		shard := &conn.shard[i]
@@ -121,7 +209,21 @@ func Connect(addr string, opts Opts) (*Connection, error) {
 		}
 	}
 
-	if err := conn.createConnection(false); err != nil {
+	if conn.opts.Lazy {
+		if conn.opts.Reconnect <= 0 {
+			conn.opts.Reconnect = time.Second
+		}
+
+		conn.ready = make(chan struct{})
+
+		go func(conn *Connection) {
+			conn.mutex.Lock()
+			defer conn.mutex.Unlock()
+			if err := conn.createConnection(true, true); err != nil {
+				conn.closeConnection(err, true)
+			}
+		}(conn)
+	} else if err := conn.createConnection(false, true); err != nil {
 		ter, ok := err.(Error)
 
 		switch {
@@ -135,20 +237,26 @@ func Connect(addr string, opts Opts) (*Connection, error) {
 			go func(conn *Connection) {
 				conn.mutex.Lock()
 				defer conn.mutex.Unlock()
-				if err := conn.createConnection(true); err != nil {
+				if err := conn.createConnection(true, true); err != nil {
 					conn.closeConnection(err, true)
 				}
 			}(conn)
 		}
 	}
 
-	go conn.pinger()
+	if conn.opts.PingInterval >= 0 {
+		go conn.pinger()
+	}
 
 	if conn.opts.Timeout > 0 {
 		go conn.timeouts()
 	}
 
-	if !conn.opts.SkipSchema {
+	if conn.opts.OfflineQueue > 0 {
+		go conn.offlineTimeouts()
+	}
+
+	if !conn.opts.SkipSchema && !conn.opts.Lazy {
 		if err := conn.loadSchema(); err != nil {
 			conn.mutex.Lock()
 			conn.closeConnection(err, true)
@@ -156,6 +264,8 @@ func Connect(addr string, opts Opts) (*Connection, error) {
 
 			return nil, err
 		}
+
+		conn.Schema = conn.GetSchema()
 	}
 
 	return conn, nil
@@ -178,7 +288,9 @@ const (
 	connClosed       = 2
 )
 
-func (conn *Connection) createConnection(reconnect bool) error {
+// createConnection dials until connection is established if reconnect is set.
+// The first connection of Connection is not counted as reconnect.
+func (conn *Connection) createConnection(reconnect, first bool) error {
 	var reconnects uint
 
 	for conn.c == nil && conn.state == connDisconnected {
@@ -186,14 +298,27 @@ func (conn *Connection) createConnection(reconnect bool) error {
 		err := conn.dial()
 
 		if err == nil {
+			if reconnect && !first {
+				atomic.AddUint64(&conn.stats.reconnects, 1)
+			}
+
+			conn.opts.Logger.Info("tarantool: connected", "addr", conn.addr,
+				"version", conn.Greeting.Version, "reconnects", reconnects)
+
 			return nil
 		}
 
+		conn.opts.Logger.Warn("tarantool: connect failed", "addr", conn.addr,
+			"attempt", reconnects+1, "error", err)
+
 		if !reconnect {
 			return err
 		}
 
 		if conn.opts.MaxReconnects > 0 && reconnects > conn.opts.MaxReconnects {
+			conn.opts.Logger.Error("tarantool: reconnect attempts exhausted", "addr", conn.addr,
+				"max_reconnects", conn.opts.MaxReconnects)
+
 			// mark connection as closed to avoid reopening by another goroutine
 			return ClientError{ErrConnectionClosed, "last reconnect failed"}
 		}
@@ -219,18 +344,31 @@ func (conn *Connection) closeConnection(neterr error, forever bool) error {
 
 	if forever {
 		if conn.state != connClosed {
+			conn.opts.Logger.Info("tarantool: connection closed", "addr", conn.addr, "reason", neterr)
+
 			close(conn.control)
 			atomic.StoreUint32(&conn.state, connClosed)
 		}
+
+		conn.failOffline(neterr)
 	} else {
 		atomic.StoreUint32(&conn.state, connDisconnected)
 	}
 
-	if conn.c != nil {
-		err = conn.c.Close()
-		conn.c = nil
+	for i := range conn.sockets {
+		s := &conn.sockets[i]
+
+		if s.c != nil {
+			if cerr := s.c.Close(); err == nil {
+				err = cerr
+			}
+
+			s.c = nil
+		}
 	}
 
+	conn.c = nil
+
 	for i := range conn.shard {
 		requests := &conn.shard[i].requests
 		for pos := range requests {
@@ -239,11 +377,13 @@ func (conn *Connection) closeConnection(neterr error, forever bool) error {
 			requests[pos].last = &requests[pos].first
 
 			for fut != nil {
+				next := fut.next
+				fut.next = nil
 				fut.err = neterr
 
 				fut.markReady(conn)
 
-				fut, fut.next = fut.next, nil
+				fut = next
 			}
 		}
 	}
@@ -254,22 +394,85 @@ func (conn *Connection) closeConnection(neterr error, forever bool) error {
 }
 
 func (conn *Connection) pinger() {
-	to := conn.opts.Timeout
-	if to == 0 {
-		to = 3 * time.Second
+	interval := conn.opts.PingInterval
+	if interval == 0 {
+		interval = time.Second
+
+		if conn.opts.Timeout > 0 {
+			interval = conn.opts.Timeout / 3
+		}
 	}
 
-	t := time.NewTicker(to / 3)
+	t := time.NewTicker(interval)
 	defer t.Stop()
 
+	var failures uint
+
 	for {
 		select {
 		case <-conn.control:
 			return
 		case <-t.C:
-			conn.Ping()
 		}
+
+		conn.mutex.Lock()
+		c := conn.c
+		conn.mutex.Unlock()
+
+		if c == nil {
+			failures = 0
+
+			continue
+		}
+
+		err := conn.healthCheck(interval)
+		if err == nil {
+			failures = 0
+
+			continue
+		}
+
+		failures++
+
+		conn.opts.Logger.Warn("tarantool: health check failed", "addr", conn.addr,
+			"failures", failures, "error", err)
+
+		if conn.opts.PingFailures > 0 && failures >= conn.opts.PingFailures {
+			failures = 0
+
+			conn.reconnect(ClientError{ErrConnectionNotReady, "health check failed"}, c)
+		}
+	}
+}
+
+// healthCheck performs Opts.HealthCheck or ping bypassing interceptors.
+// Without Timeout it waits for response not longer than interval,
+// so half-open connections are detected too.
+func (conn *Connection) healthCheck(interval time.Duration) error {
+	timeout := conn.opts.Timeout
+	if timeout == 0 {
+		timeout = interval
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if conn.opts.HealthCheck != nil {
+		return conn.opts.HealthCheck(ctx, conn)
+	}
+
+	resp, err := conn.invoke(ctx, NewPingRequest())
+	if err != nil {
+		return err
 	}
+
+	defer resp.Release()
+
+	if resp.Code != OkCode {
+		return Error{resp.Code, resp.Error}
+	}
+
+	return nil
 }
 
 var epoch = fasttime.NowNano()
@@ -362,12 +565,77 @@ func (conn *Connection) dial() error {
 		address = address[4:]
 	}
 
+	conns := make([]net.Conn, len(conn.sockets))
+	readers := make([]*bufio.Reader, len(conn.sockets))
+	writers := make([]*bufio.Writer, len(conn.sockets))
+
+	for i := range conn.sockets {
+		var err error
+
+		conns[i], readers[i], writers[i], err = conn.dialSocket(network, address, timeout)
+		if err != nil {
+			for _, c := range conns[:i] {
+				c.Close()
+			}
+
+			return err
+		}
+	}
+
+	conn.lockShards()
+
+	conn.c = conns[0]
+
+	for i := range conn.sockets {
+		conn.sockets[i].c = conns[i]
+	}
+
+	atomic.StoreUint32(&conn.state, connConnected)
+
+	if conn.draining == ErrConnectionNotReady {
+		// previous server was shut down, new one is ready
+		conn.draining = 0
+	}
+
+	offline := conn.flushOffline()
+
+	conn.unlockShards()
+
+	for i := range conn.sockets {
+		go conn.writer(writers[i], &conn.sockets[i], conns[0])
+		go conn.reader(readers[i], &conn.sockets[i], conns[0])
+	}
+
+	if conn.ready != nil {
+		go conn.lazyInit()
+	}
+
+	if len(offline) > 0 {
+		conn.opts.Logger.Info("tarantool: sending buffered requests", "addr", conn.addr, "count", len(offline))
+
+		// writer may fail before all of them are queued,
+		// and it needs conn.mutex held by caller to reconnect
+		go func() {
+			for _, fut := range offline {
+				conn.queueOf(fut.request.requestId) <- fut
+			}
+		}()
+	}
+
+	conn.watch(boxShutdownEvent)
+
+	return nil
+}
+
+// dialSocket establishes one of physical connections: reads greeting
+// and performs authorization.
+func (conn *Connection) dialSocket(network, address string, timeout time.Duration) (net.Conn, *bufio.Reader, *bufio.Writer, error) {
 	connection, err := net.DialTimeout(network, address, timeout)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 
-	dc := &DeadlineIO{to: conn.opts.Timeout, c: connection}
+	dc := &DeadlineIO{to: conn.opts.Timeout, c: connection, stats: &conn.stats}
 	r := bufio.NewReaderSize(dc, 128*1024)
 	w := bufio.NewWriterSize(dc, 128*1024)
 
@@ -376,10 +644,15 @@ func (conn *Connection) dial() error {
 	if _, err = io.ReadFull(r, greeting); err != nil {
 		connection.Close()
 
-		return err
+		return nil, nil, nil, err
 	}
 
 	conn.Greeting.Version = string(greeting[:64])
+
+	// "Tarantool 2.10.0 (Binary) 7170b4af-c72f-4f07-8729-08fc678543a1"
+	if fields := strings.Fields(conn.Greeting.Version); len(fields) > 0 {
+		conn.Greeting.UUID, _ = ParseUUID(fields[len(fields)-1])
+	}
 	conn.Greeting.auth = string(greeting[64:108])
 
 	// Auth
@@ -388,43 +661,40 @@ func (conn *Connection) dial() error {
 		if err != nil {
 			connection.Close()
 
-			return errors.New("auth: scrambling failure " + err.Error())
+			return nil, nil, nil, errors.New("auth: scrambling failure " + err.Error())
 		}
 
 		if err = conn.writeAuthRequest(w, scr); err != nil {
 			connection.Close()
 
-			return err
+			return nil, nil, nil, err
 		}
 
 		if err = conn.readAuthResponse(r); err != nil {
 			connection.Close()
 
-			return err
+			conn.opts.Logger.Error("tarantool: auth failed", "addr", conn.addr,
+				"user", conn.opts.User, "error", err)
+
+			return nil, nil, nil, err
 		}
 	}
 
-	conn.lockShards()
-
-	conn.c = connection
-
-	atomic.StoreUint32(&conn.state, connConnected)
-	conn.unlockShards()
-
-	go conn.writer(w, connection)
-	go conn.reader(r, connection)
-
-	return nil
+	return connection, r, w, nil
 }
 
 func (conn *Connection) reconnect(neterr error, c net.Conn) {
 	conn.mutex.Lock()
 
+	if c == conn.c && conn.state == connConnected {
+		conn.opts.Logger.Error("tarantool: connection failed", "addr", conn.addr, "error", neterr)
+	}
+
 	if conn.opts.Reconnect > 0 {
 		if c == conn.c {
 			conn.closeConnection(neterr, false)
 
-			if err := conn.createConnection(true); err != nil {
+			if err := conn.createConnection(true, false); err != nil {
 				conn.closeConnection(err, true)
 			}
 		}
@@ -453,11 +723,35 @@ func (conn *Connection) nextRequestId() (requestId uint32) {
 
 type Greeting struct {
 	Version string
-	auth    string
+	// UUID is instance uuid, it is zero if tarantool doesn't send it.
+	UUID UUID
+	auth string
 }
 
 const requestsMap = 128
 
+// socket is one of physical connections of Connection.
+type socket struct {
+	c      net.Conn
+	queue  chan *Future
+	lenBuf [5]byte
+}
+
+// queueOf returns queue of socket which sends request.
+func (conn *Connection) queueOf(requestId uint32) chan *Future {
+	shardn := requestId & (conn.opts.Concurrency - 1)
+
+	return conn.sockets[shardn%uint32(len(conn.sockets))].queue
+}
+
+func (conn *Connection) queueDepth() (depth int) {
+	for i := range conn.sockets {
+		depth += len(conn.sockets[i].queue)
+	}
+
+	return depth
+}
+
 type connShard struct {
 	rmut     spinlock.Locker
 	requests [requestsMap]struct {
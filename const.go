@@ -1,18 +1,23 @@
 package tarantool
 
 const (
-	SelectRequest    = 1
-	InsertRequest    = 2
-	ReplaceRequest   = 3
-	UpdateRequest    = 4
-	DeleteRequest    = 5
-	CallRequest      = 6 /* call in 1.6 format */
-	AuthRequest      = 7
-	EvalRequest      = 8
-	UpsertRequest    = 9
-	Call17Request    = 10
-	PingRequest      = 64
-	SubscribeRequest = 66
+	SelectRequest        = 1
+	InsertRequest        = 2
+	ReplaceRequest       = 3
+	UpdateRequest        = 4
+	DeleteRequest        = 5
+	CallRequest          = 6 /* call in 1.6 format */
+	AuthRequest          = 7
+	EvalRequest          = 8
+	UpsertRequest        = 9
+	Call17Request        = 10
+	NopRequest           = 12 /* empty row of xlog */
+	PingRequest          = 64
+	JoinRequest          = 65
+	SubscribeRequest     = 66
+	FetchSnapshotRequest = 69 /* join of anonymous replica */
+	WatchRequest         = 74
+	EventRequest         = 76 /* sent by tarantool on watched key change */
 
 	KeyCode         = 0x00
 	KeySync         = 0x01
@@ -21,14 +26,20 @@ const (
 	KeyLimit        = 0x12
 	KeyOffset       = 0x13
 	KeyIterator     = 0x14
+	KeyFetchPos     = 0x1f
 	KeyKey          = 0x20
 	KeyTuple        = 0x21
 	KeyFunctionName = 0x22
 	KeyUserName     = 0x23
 	KeyExpression   = 0x27
 	KeyDefTuple     = 0x28
+	KeyAfterPos     = 0x2e
+	KeyAfterTuple   = 0x2f
 	KeyData         = 0x30
 	KeyError        = 0x31
+	KeyPosition     = 0x35
+	KeyEventKey     = 0x57
+	KeyEventData    = 0x58
 
 	// https://github.com/fl00r/go-tarantool-1.6/issues/2
 
@@ -0,0 +1,105 @@
+package tarantool
+
+import (
+	"context"
+)
+
+// SelectOpts are options of select request supported by tarantool 2.11+.
+type SelectOpts struct {
+	// After is a position returned in Response.Position by previous select,
+	// tuples are selected starting after it.
+	After []byte
+	// AfterTuple is a tuple, tuples are selected starting after it.
+	// It is ignored if After is set.
+	AfterTuple Body
+	// FetchPos requests position of the last selected tuple.
+	FetchPos bool
+}
+
+// WithSelectOpts returns copy of select request with opts applied.
+// It does nothing for other requests.
+func (req Request) WithSelectOpts(opts SelectOpts) Request {
+	if req.r.requestCode != SelectRequest {
+		return req
+	}
+
+	req.r.after = opts.After
+	req.r.afterTuple = opts.AfterTuple
+	req.r.fetchPos = opts.FetchPos
+
+	return req
+}
+
+// Cursor pages through index using positions of selected tuples,
+// so every page costs the same regardless of its number.
+// It requires tarantool 2.11+ and tree index.
+type Cursor struct {
+	conn     *Connection
+	space    uint32
+	index    uint32
+	limit    uint32
+	iterator uint32
+	key      Body
+	pos      []byte
+	done     bool
+}
+
+// NewCursor creates cursor over tuples of index matching key and iterator.
+func (conn *Connection) NewCursor(space, index, limit, iterator uint32, key Body) *Cursor {
+	return &Cursor{
+		conn:     conn,
+		space:    space,
+		index:    index,
+		limit:    limit,
+		iterator: iterator,
+		key:      key,
+	}
+}
+
+// Next selects next page of tuples. Response must be released by caller.
+// Done returns true after the last page is selected.
+func (cur *Cursor) Next(ctx context.Context) (Response, error) {
+	if cur.done {
+		return Response{}, nil
+	}
+
+	req := NewSelectRequest(cur.space, cur.index, 0, cur.limit, cur.iterator, cur.key).
+		WithSelectOpts(SelectOpts{After: cur.pos, FetchPos: true})
+
+	resp, err := cur.conn.Do(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.Code != OkCode {
+		return resp, nil
+	}
+
+	it := resp.Tuples()
+
+	if it.Len() < int(cur.limit) || len(resp.Position) == 0 {
+		cur.done = true
+	}
+
+	// response buffer is released by caller
+	cur.pos = append(cur.pos[:0], resp.Position...)
+
+	return resp, nil
+}
+
+// Done returns true if there are no more pages.
+func (cur *Cursor) Done() bool {
+	return cur.done
+}
+
+// Position returns position after the last selected page,
+// it could be used to resume paging with Seek.
+func (cur *Cursor) Position() []byte {
+	return cur.pos
+}
+
+// Seek makes cursor continue paging after pos.
+func (cur *Cursor) Seek(pos []byte) {
+	cur.pos = append(cur.pos[:0], pos...)
+	cur.done = false
+}
@@ -0,0 +1,176 @@
+package tarantool
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+)
+
+// DatetimeExtension is msgpack extension type of tarantool datetime.
+// msgp reserves it for complex128, so datetime is not decoded by
+// msgp.ReadIntfBytes and should be decoded into Datetime explicitly.
+const DatetimeExtension = 4
+
+// Datetime is tarantool datetime: time with timezone offset
+// and, optionally, index of timezone in tarantool timezones table.
+type Datetime struct {
+	// Time is encoded with offset of its zone, with minute precision.
+	Time time.Time
+	// TzIndex is index of timezone in tarantool timezones table,
+	// zero if timezone is set with offset only.
+	TzIndex int16
+}
+
+// NewDatetime creates datetime from t.
+func NewDatetime(t time.Time) Datetime {
+	return Datetime{Time: t}
+}
+
+// String returns datetime in RFC3339 with nanoseconds.
+func (dt Datetime) String() string {
+	return dt.Time.Format(time.RFC3339Nano)
+}
+
+// Add returns datetime shifted by interval as tarantool does:
+// years and months are added first according to ival.Adjust,
+// then weeks and days keeping time of day, and then the rest.
+func (dt Datetime) Add(ival Interval) Datetime {
+	t := dt.Time
+
+	if ival.Year != 0 || ival.Month != 0 {
+		t = addMonths(t, ival.Year*12+ival.Month, ival.Adjust)
+	}
+
+	if days := ival.Week*7 + ival.Day; days != 0 {
+		t = t.AddDate(0, 0, int(days))
+	}
+
+	t = t.Add(time.Duration(ival.Hour)*time.Hour +
+		time.Duration(ival.Min)*time.Minute +
+		time.Duration(ival.Sec)*time.Second +
+		time.Duration(ival.Nsec))
+
+	return Datetime{Time: t, TzIndex: dt.TzIndex}
+}
+
+// Sub returns datetime shifted back by interval, see Add.
+func (dt Datetime) Sub(ival Interval) Datetime {
+	return dt.Add(ival.Neg())
+}
+
+func addMonths(t time.Time, months int64, adjust Adjust) time.Time {
+	y, m, d := t.Date()
+	hh, mm, ss := t.Clock()
+
+	if adjust == ExcessAdjust {
+		// days after the end of month overflow to the next one
+		return time.Date(y, m+time.Month(months), d, hh, mm, ss, t.Nanosecond(), t.Location())
+	}
+
+	last := daysIn(y, m)
+
+	// first day of target month
+	first := time.Date(y, m+time.Month(months), 1, hh, mm, ss, t.Nanosecond(), t.Location())
+	target := daysIn(first.Year(), first.Month())
+
+	if d > target || (adjust == LastAdjust && d == last) {
+		d = target
+	}
+
+	return first.AddDate(0, 0, d-1)
+}
+
+func daysIn(y int, m time.Month) int {
+	return time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// ExtensionType implements msgp.Extension.
+func (dt *Datetime) ExtensionType() int8 {
+	return DatetimeExtension
+}
+
+func (dt *Datetime) offset() int16 {
+	_, offset := dt.Time.Zone()
+
+	return int16(offset / 60)
+}
+
+// Len implements msgp.Extension.
+func (dt *Datetime) Len() int {
+	if dt.Time.Nanosecond() != 0 || dt.offset() != 0 || dt.TzIndex != 0 {
+		return 16
+	}
+
+	return 8
+}
+
+// MarshalBinaryTo implements msgp.Extension.
+func (dt *Datetime) MarshalBinaryTo(b []byte) error {
+	binary.LittleEndian.PutUint64(b, uint64(dt.Time.Unix()))
+
+	if len(b) < 16 {
+		return nil
+	}
+
+	binary.LittleEndian.PutUint32(b[8:], uint32(dt.Time.Nanosecond()))
+	binary.LittleEndian.PutUint16(b[12:], uint16(dt.offset()))
+	binary.LittleEndian.PutUint16(b[14:], uint16(dt.TzIndex))
+
+	return nil
+}
+
+// UnmarshalBinary implements msgp.Extension.
+func (dt *Datetime) UnmarshalBinary(b []byte) error {
+	var (
+		nsec   int32
+		offset int16
+	)
+
+	switch len(b) {
+	case 16:
+		nsec = int32(binary.LittleEndian.Uint32(b[8:]))
+		offset = int16(binary.LittleEndian.Uint16(b[12:]))
+		dt.TzIndex = int16(binary.LittleEndian.Uint16(b[14:]))
+	case 8:
+		dt.TzIndex = 0
+	default:
+		return errors.Errorf("can't decode datetime of %d bytes", len(b))
+	}
+
+	t := time.Unix(int64(binary.LittleEndian.Uint64(b)), int64(nsec))
+
+	if offset == 0 {
+		dt.Time = t.UTC()
+	} else {
+		dt.Time = t.In(time.FixedZone("", int(offset)*60))
+	}
+
+	return nil
+}
+
+// EncodeMsg implements msgp.Encodable.
+func (dt *Datetime) EncodeMsg(w *msgp.Writer) error {
+	return w.WriteExtension(dt)
+}
+
+// DecodeMsg implements msgp.Decodable.
+func (dt *Datetime) DecodeMsg(r *msgp.Reader) error {
+	return r.ReadExtension(dt)
+}
+
+// MarshalMsg implements msgp.Marshaler.
+func (dt *Datetime) MarshalMsg(b []byte) ([]byte, error) {
+	return msgp.AppendExtension(b, dt)
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler.
+func (dt *Datetime) UnmarshalMsg(b []byte) ([]byte, error) {
+	return msgp.ReadExtensionBytes(b, dt)
+}
+
+// Msgsize implements msgp.Sizer.
+func (dt *Datetime) Msgsize() int {
+	return extensionSize(dt.Len())
+}
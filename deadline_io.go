@@ -2,6 +2,7 @@ package tarantool
 
 import (
 	"net"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -9,8 +10,9 @@ import (
 )
 
 type DeadlineIO struct {
-	to time.Duration
-	c  net.Conn
+	to    time.Duration
+	c     net.Conn
+	stats *connStats
 }
 
 func (d *DeadlineIO) getDeadline() time.Time {
@@ -30,7 +32,12 @@ func (d *DeadlineIO) Write(b []byte) (int, error) {
 		d.c.SetWriteDeadline(d.getDeadline())
 	}
 
-	return d.c.Write(b)
+	n, err := d.c.Write(b)
+	if d.stats != nil {
+		atomic.AddUint64(&d.stats.written, uint64(n))
+	}
+
+	return n, err
 }
 
 func (d *DeadlineIO) Read(b []byte) (int, error) {
@@ -38,5 +45,10 @@ func (d *DeadlineIO) Read(b []byte) (int, error) {
 		d.c.SetReadDeadline(d.getDeadline())
 	}
 
-	return d.c.Read(b)
+	n, err := d.c.Read(b)
+	if d.stats != nil {
+		atomic.AddUint64(&d.stats.read, uint64(n))
+	}
+
+	return n, err
 }
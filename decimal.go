@@ -0,0 +1,270 @@
+package tarantool
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+)
+
+// DecimalExtension is msgpack extension type of tarantool decimal.
+const DecimalExtension = 1
+
+// Decimal is tarantool decimal number: coefficient * 10^exponent.
+//
+// It has the same representation as github.com/shopspring/decimal,
+// they are converted without loss by module
+// gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/shopspring.
+type Decimal struct {
+	coef *big.Int
+	exp  int32
+}
+
+// NewDecimal creates decimal coef * 10^exp.
+func NewDecimal(coef *big.Int, exp int32) Decimal {
+	return Decimal{coef: new(big.Int).Set(coef), exp: exp}
+}
+
+// NewDecimalFromInt64 creates decimal of integer value.
+func NewDecimalFromInt64(v int64) Decimal {
+	return Decimal{coef: big.NewInt(v)}
+}
+
+// ParseDecimal parses decimal in plain or scientific notation, ie "-12.34" or "1.5e3".
+func ParseDecimal(s string) (Decimal, error) {
+	var d Decimal
+
+	str := s
+
+	if i := strings.IndexAny(str, "eE"); i >= 0 {
+		exp, err := strconv.ParseInt(str[i+1:], 10, 32)
+		if err != nil {
+			return d, errors.Wrapf(err, "can't parse decimal %q", s)
+		}
+
+		d.exp = int32(exp)
+		str = str[:i]
+	}
+
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		d.exp -= int32(len(str) - i - 1)
+		str = str[:i] + str[i+1:]
+	}
+
+	if str == "" || str == "-" || str == "+" || strings.ContainsAny(str[1:], "+-") {
+		return Decimal{}, errors.Errorf("can't parse decimal %q", s)
+	}
+
+	coef, ok := new(big.Int).SetString(str, 10)
+	if !ok {
+		return Decimal{}, errors.Errorf("can't parse decimal %q", s)
+	}
+
+	d.coef = coef
+
+	return d, nil
+}
+
+// Coefficient returns copy of decimal coefficient.
+func (d Decimal) Coefficient() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+
+	return new(big.Int).Set(d.coef)
+}
+
+// Exponent returns decimal exponent.
+func (d Decimal) Exponent() int32 {
+	return d.exp
+}
+
+// Sign returns -1, 0 or 1 depending on sign of d.
+func (d Decimal) Sign() int {
+	if d.coef == nil {
+		return 0
+	}
+
+	return d.coef.Sign()
+}
+
+// String returns decimal in plain notation as tarantool does, ie "-12.340".
+func (d Decimal) String() string {
+	digits := d.digits()
+
+	var b strings.Builder
+
+	if d.Sign() < 0 {
+		b.WriteByte('-')
+	}
+
+	switch scale := -int(d.exp); {
+	case scale <= 0:
+		b.WriteString(digits)
+		b.WriteString(strings.Repeat("0", -scale))
+	case len(digits) <= scale:
+		b.WriteString("0.")
+		b.WriteString(strings.Repeat("0", scale-len(digits)))
+		b.WriteString(digits)
+	default:
+		b.WriteString(digits[:len(digits)-scale])
+		b.WriteByte('.')
+		b.WriteString(digits[len(digits)-scale:])
+	}
+
+	return b.String()
+}
+
+// digits returns decimal digits of absolute value of coefficient.
+func (d Decimal) digits() string {
+	if d.coef == nil {
+		return "0"
+	}
+
+	return new(big.Int).Abs(d.coef).String()
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Decimal) UnmarshalText(text []byte) (err error) {
+	*d, err = ParseDecimal(string(text))
+
+	return err
+}
+
+// ExtensionType implements msgp.Extension.
+func (d *Decimal) ExtensionType() int8 {
+	return DecimalExtension
+}
+
+// Len implements msgp.Extension.
+func (d *Decimal) Len() int {
+	// scale, digits and sign nibble
+	return len(msgp.AppendInt64(nil, -int64(d.exp))) + (len(d.digits())+2)/2
+}
+
+// MarshalBinaryTo implements msgp.Extension.
+func (d *Decimal) MarshalBinaryTo(b []byte) error {
+	b = msgp.AppendInt64(b[:0], -int64(d.exp))
+
+	digits := d.digits()
+
+	sign := byte(0x0c)
+	if d.Sign() < 0 {
+		sign = 0x0d
+	}
+
+	// nibbles are aligned to the end, the last one is sign
+	nibbles := len(digits) + 1
+	if nibbles%2 == 1 {
+		nibbles++
+		digits = "0" + digits
+	}
+
+	for i := 0; i < nibbles/2; i++ {
+		hi := digits[2*i] - '0'
+
+		lo := sign
+		if 2*i+1 < len(digits) {
+			lo = digits[2*i+1] - '0'
+		}
+
+		b = append(b, hi<<4|lo)
+	}
+
+	return nil
+}
+
+// UnmarshalBinary implements msgp.Extension.
+func (d *Decimal) UnmarshalBinary(b []byte) error {
+	scale, bcd, err := msgp.ReadInt64Bytes(b)
+	if err != nil {
+		return errors.Wrap(err, "can't decode decimal scale")
+	}
+
+	if len(bcd) == 0 {
+		return errors.New("can't decode decimal: no digits")
+	}
+
+	digits := make([]byte, 0, 2*len(bcd))
+
+	for _, c := range bcd {
+		digits = append(digits, '0'+c>>4, '0'+c&0x0f)
+	}
+
+	sign := digits[len(digits)-1] - '0'
+	digits = digits[:len(digits)-1]
+
+	for _, c := range digits {
+		if c > '9' {
+			return errors.New("can't decode decimal: wrong digit")
+		}
+	}
+
+	coef, ok := new(big.Int).SetString(string(digits), 10)
+	if !ok {
+		return errors.New("can't decode decimal digits")
+	}
+
+	switch sign {
+	case 0x0b, 0x0d:
+		coef.Neg(coef)
+	case 0x0a, 0x0c, 0x0e, 0x0f:
+	default:
+		return errors.New("can't decode decimal: wrong sign")
+	}
+
+	d.coef = coef
+	d.exp = -int32(scale)
+
+	return nil
+}
+
+// EncodeMsg implements msgp.Encodable.
+func (d *Decimal) EncodeMsg(w *msgp.Writer) error {
+	return w.WriteExtension(d)
+}
+
+// DecodeMsg implements msgp.Decodable.
+func (d *Decimal) DecodeMsg(r *msgp.Reader) error {
+	return r.ReadExtension(d)
+}
+
+// MarshalMsg implements msgp.Marshaler.
+func (d *Decimal) MarshalMsg(b []byte) ([]byte, error) {
+	return msgp.AppendExtension(b, d)
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler.
+func (d *Decimal) UnmarshalMsg(b []byte) ([]byte, error) {
+	return msgp.ReadExtensionBytes(b, d)
+}
+
+// Msgsize implements msgp.Sizer.
+func (d *Decimal) Msgsize() int {
+	return extensionSize(d.Len())
+}
+
+// extensionSize returns exact size of encoded extension with data of l bytes.
+func extensionSize(l int) int {
+	switch {
+	case l == 1 || l == 2 || l == 4 || l == 8 || l == 16:
+		return 2 + l
+	case l < 256:
+		return 3 + l
+	case l < 65536:
+		return 4 + l
+	default:
+		return 6 + l
+	}
+}
+
+func init() {
+	msgp.RegisterExtension(DecimalExtension, func() msgp.Extension { return new(Decimal) })
+}
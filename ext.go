@@ -0,0 +1,320 @@
+package tarantool
+
+import (
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+)
+
+// ExtDecoder decodes data of msgpack extension into Go value.
+type ExtDecoder func(data []byte) (interface{}, error)
+
+var extDecoders = struct {
+	sync.RWMutex
+	m map[int8]ExtDecoder
+}{m: make(map[int8]ExtDecoder)}
+
+// RegisterExt registers decoder of msgpack extension type, which is used by
+// Decode, Response.Values and AppendJSON. It replaces previous decoder of
+// the type, so builtin decoders could be overridden too.
+func RegisterExt(typ int8, decoder ExtDecoder) {
+	extDecoders.Lock()
+	extDecoders.m[typ] = decoder
+	extDecoders.Unlock()
+}
+
+// DecodeExt decodes extension data with registered decoder.
+// Extensions without decoder are returned as *msgp.RawExtension.
+func DecodeExt(typ int8, data []byte) (interface{}, error) {
+	extDecoders.RLock()
+	decoder := extDecoders.m[typ]
+	extDecoders.RUnlock()
+
+	if decoder == nil {
+		return &msgp.RawExtension{Type: typ, Data: append([]byte(nil), data...)}, nil
+	}
+
+	return decoder(data)
+}
+
+// readExt reads extension type and data.
+func readExt(b []byte) (typ int8, data []byte, remain []byte, err error) {
+	if len(b) < 2 {
+		return 0, nil, b, msgp.ErrShortBytes
+	}
+
+	var size, off int
+
+	switch lead := b[0]; lead {
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		// fixext 1, 2, 4, 8, 16
+		size, off = 1<<(lead-0xd4), 2
+	case 0xc7:
+		if len(b) < 3 {
+			return 0, nil, b, msgp.ErrShortBytes
+		}
+
+		size, off = int(b[1]), 3
+	case 0xc8:
+		if len(b) < 4 {
+			return 0, nil, b, msgp.ErrShortBytes
+		}
+
+		size, off = int(binary.BigEndian.Uint16(b[1:])), 4
+	case 0xc9:
+		if len(b) < 6 {
+			return 0, nil, b, msgp.ErrShortBytes
+		}
+
+		size, off = int(binary.BigEndian.Uint32(b[1:])), 6
+	default:
+		return 0, nil, b, errors.Errorf("msgpack extension expected, got 0x%x", lead)
+	}
+
+	if len(b) < off+size {
+		return 0, nil, b, msgp.ErrShortBytes
+	}
+
+	return int8(b[off-1]), b[off : off+size], b[off+size:], nil
+}
+
+func isExt(b []byte) bool {
+	return len(b) > 0 && (b[0] >= 0xd4 && b[0] <= 0xd8 || b[0] >= 0xc7 && b[0] <= 0xc9)
+}
+
+// Decode decodes msgpack value. Arrays are decoded as []interface{},
+// maps as map[interface{}]interface{} and extensions with registered
+// decoders, the rest is decoded as msgp.ReadIntfBytes does.
+func Decode(b []byte) (interface{}, []byte, error) {
+	if isExt(b) {
+		typ, data, remain, err := readExt(b)
+		if err != nil {
+			return nil, b, err
+		}
+
+		v, err := DecodeExt(typ, data)
+
+		return v, remain, err
+	}
+
+	switch msgp.NextType(b) {
+	case msgp.ArrayType:
+		n, remain, err := msgp.ReadArrayHeaderBytes(b)
+		if err != nil {
+			return nil, b, err
+		}
+
+		arr := make([]interface{}, n)
+
+		for i := range arr {
+			if arr[i], remain, err = Decode(remain); err != nil {
+				return nil, b, err
+			}
+		}
+
+		return arr, remain, nil
+	case msgp.MapType:
+		n, remain, err := msgp.ReadMapHeaderBytes(b)
+		if err != nil {
+			return nil, b, err
+		}
+
+		m := make(map[interface{}]interface{}, n)
+
+		for i := uint32(0); i < n; i++ {
+			var k, v interface{}
+
+			if k, remain, err = Decode(remain); err != nil {
+				return nil, b, err
+			}
+
+			switch k.(type) {
+			case []interface{}, map[interface{}]interface{}, []byte:
+				return nil, b, errors.Errorf("unsupported map key of type %T", k)
+			}
+
+			if v, remain, err = Decode(remain); err != nil {
+				return nil, b, err
+			}
+
+			m[k] = v
+		}
+
+		return m, remain, nil
+	default:
+		return msgp.ReadIntfBytes(b)
+	}
+}
+
+// Values decodes tuples of response data with registered extension decoders.
+func (resp *Response) Values() ([]interface{}, error) {
+	if len(resp.Data) == 0 {
+		return nil, nil
+	}
+
+	v, _, err := Decode(resp.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("response data is %T, not array", v)
+	}
+
+	return values, nil
+}
+
+// Values decodes fields of the current tuple with registered extension decoders.
+func (it *TupleIterator) Values() ([]interface{}, error) {
+	v, _, err := Decode(it.tuple)
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("tuple is %T, not array", v)
+	}
+
+	return values, nil
+}
+
+// AppendJSON appends msgpack value converted to JSON to dst. Extensions
+// are decoded with registered decoders, and then encoded as text if they
+// implement encoding.TextMarshaler, fmt.Stringer or error, or with json.Marshal.
+func AppendJSON(dst []byte, b []byte) ([]byte, []byte, error) {
+	if isExt(b) {
+		v, remain, err := Decode(b)
+		if err != nil {
+			return dst, b, err
+		}
+
+		var enc []byte
+
+		switch v := v.(type) {
+		case encoding.TextMarshaler:
+			var text []byte
+
+			if text, err = v.MarshalText(); err == nil {
+				enc, err = json.Marshal(string(text))
+			}
+		case fmt.Stringer:
+			enc, err = json.Marshal(v.String())
+		case error:
+			enc, err = json.Marshal(v.Error())
+		default:
+			enc, err = json.Marshal(v)
+		}
+
+		if err != nil {
+			return dst, b, err
+		}
+
+		return append(dst, enc...), remain, nil
+	}
+
+	switch msgp.NextType(b) {
+	case msgp.ArrayType:
+		n, remain, err := msgp.ReadArrayHeaderBytes(b)
+		if err != nil {
+			return dst, b, err
+		}
+
+		dst = append(dst, '[')
+
+		for i := uint32(0); i < n; i++ {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+
+			if dst, remain, err = AppendJSON(dst, remain); err != nil {
+				return dst, b, err
+			}
+		}
+
+		return append(dst, ']'), remain, nil
+	case msgp.MapType:
+		n, remain, err := msgp.ReadMapHeaderBytes(b)
+		if err != nil {
+			return dst, b, err
+		}
+
+		dst = append(dst, '{')
+
+		for i := uint32(0); i < n; i++ {
+			var k interface{}
+
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+
+			if k, remain, err = Decode(remain); err != nil {
+				return dst, b, err
+			}
+
+			key, ok := k.(string)
+			if !ok {
+				key = fmt.Sprint(k)
+			}
+
+			enc, _ := json.Marshal(key)
+			dst = append(append(dst, enc...), ':')
+
+			if dst, remain, err = AppendJSON(dst, remain); err != nil {
+				return dst, b, err
+			}
+		}
+
+		return append(dst, '}'), remain, nil
+	default:
+		v, remain, err := msgp.ReadIntfBytes(b)
+		if err != nil {
+			return dst, b, err
+		}
+
+		enc, err := json.Marshal(v)
+		if err != nil {
+			return dst, b, err
+		}
+
+		return append(dst, enc...), remain, nil
+	}
+}
+
+func init() {
+	RegisterExt(DecimalExtension, func(data []byte) (interface{}, error) {
+		v := new(Decimal)
+
+		return v, v.UnmarshalBinary(data)
+	})
+
+	RegisterExt(UUIDExtension, func(data []byte) (interface{}, error) {
+		v := new(UUID)
+
+		return v, v.UnmarshalBinary(data)
+	})
+
+	RegisterExt(ErrorExtension, func(data []byte) (interface{}, error) {
+		v := new(BoxError)
+
+		return v, v.UnmarshalBinary(data)
+	})
+
+	RegisterExt(DatetimeExtension, func(data []byte) (interface{}, error) {
+		v := new(Datetime)
+
+		return v, v.UnmarshalBinary(data)
+	})
+
+	RegisterExt(IntervalExtension, func(data []byte) (interface{}, error) {
+		v := new(Interval)
+
+		return v, v.UnmarshalBinary(data)
+	})
+}
@@ -2,6 +2,9 @@ package tarantool
 
 import (
 	"bufio"
+	"context"
+	"sync"
+	"sync/atomic"
 
 	"github.com/GoWebProd/gip/allocator"
 	"github.com/GoWebProd/gip/cond"
@@ -20,16 +23,63 @@ type Body interface {
 type Future struct {
 	request request
 	timeout int64
+	start   int64
 	resp    Response
 	err     error
 	ready   cond.Single
+	state   uint32
+	refs    int32
 	header  [14]byte
 
+	// inflight is set when future holds a slot of Opts.MaxInFlight
+	inflight bool
+
 	next *Future
 }
 
+const (
+	futurePending = 0
+	futureHooked  = 1
+	futureReady   = 2
+)
+
+// futureHooks keeps completion subscribers of futures.
+// Futures are allocated outside of the Go heap, so everything
+// they have to keep alive is stored here instead.
+var futureHooks = struct {
+	sync.Mutex
+	m map[*Future]*hooks
+}{m: make(map[*Future]*hooks)}
+
+type hooks struct {
+	done     chan struct{}
+	waiters  []func()
+	callback func(Response, error)
+}
+
+// Release releases the future without waiting for its result.
+// Response, if it is received later, is released automatically.
 func (fut *Future) Release() {
-	allocator.FreeObject(fut)
+	debugConsumeFuture(fut)
+
+	fut.unref()
+}
+
+// unref drops one of future references: consumer, writer or completion.
+// Future is freed when all of them are dropped.
+func (fut *Future) unref() {
+	if atomic.AddInt32(&fut.refs, -1) != 0 {
+		return
+	}
+
+	// response was received, but nobody took it
+	if fut.resp.buf != nil {
+		fut.resp.Release()
+	}
+
+	if debugFreeFuture(fut) {
+		allocator.FreeObject(fut)
+	}
 }
 
 func (fut *Future) requestLength() int {
@@ -64,61 +114,292 @@ func (fut *Future) write(w *bufio.Writer, pack *msgp.Writer) error {
 	return nil
 }
 
+// Get waits for the future to be completed and returns its result.
+// Future is released, so it must not be used after Get.
 func (fut *Future) Get() (Response, error) {
+	debugConsumeFuture(fut)
+
+	return fut.get()
+}
+
+func (fut *Future) get() (Response, error) {
 	fut.ready.Wait()
 
-	if fut.err != nil {
-		return Response{}, fut.err
-	}
+	resp, err := fut.resp, fut.err
+	rid := fut.request.requestId
+	fut.resp = Response{}
 
-	resp := fut.resp
+	fut.unref()
 
-	fut.Release()
+	if err != nil {
+		return Response{RequestId: rid}, err
+	}
 
 	return resp, nil
 }
 
 func (conn *Connection) newFuture(request request) *Future {
+	return conn.newFutureContext(context.Background(), request)
+}
+
+func (conn *Connection) newFutureContext(ctx context.Context, request request) *Future {
 	fut := allocator.AllocObject[Future]()
+	debugAllocFuture(fut)
 
+	// consumer, writer and completion
+	fut.refs = 3
 	fut.request = request
 	fut.request.requestId = conn.nextRequestId()
 
+	if conn.inflight != nil {
+		if err := conn.acquireInFlight(ctx); err != nil {
+			return fut.reject(conn, err)
+		}
+
+		fut.inflight = true
+	}
+
 	shardn := fut.request.requestId & (conn.opts.Concurrency - 1)
 	shard := &conn.shard[shardn]
 
 	shard.rmut.Lock()
-	switch conn.state {
-	case connClosed:
-		fut.err = ClientError{ErrConnectionClosed, "using closed connection"}
+	switch {
+	case conn.state == connClosed:
+		shard.rmut.Unlock()
 
+		return fut.reject(conn, ClientError{ErrConnectionClosed, "using closed connection"})
+	case conn.state == connDisconnected && conn.draining != ErrConnectionClosed:
+		buffered := conn.bufferOffline(fut)
 		shard.rmut.Unlock()
 
-		return fut
-	case connDisconnected:
-		fut.err = ClientError{ErrConnectionNotReady, "client connection is not ready"}
+		if buffered {
+			return fut
+		}
 
+		return fut.reject(conn, ClientError{ErrConnectionNotReady, "client connection is not ready"})
+	case conn.draining != 0:
+		buffered := conn.draining == ErrConnectionNotReady && conn.bufferOffline(fut)
+		code := conn.draining
 		shard.rmut.Unlock()
 
-		return fut
+		if buffered {
+			return fut
+		}
+
+		return fut.reject(conn, ClientError{code, "connection is shutting down"})
 	}
 
+	conn.putFuture(shard, fut)
+
+	shard.rmut.Unlock()
+
+	conn.queueOf(fut.request.requestId) <- fut
+
+	return fut
+}
+
+// putFuture adds future to shard, it must be called with shard lock held.
+func (conn *Connection) putFuture(shard *connShard, fut *Future) {
 	pos := (fut.request.requestId / conn.opts.Concurrency) & (requestsMap - 1)
 	pair := &shard.requests[pos]
 	*pair.last = fut
 	pair.last = &fut.next
 
+	fut.timeout = 0
 	if conn.opts.Timeout > 0 {
 		fut.timeout = fasttime.NowNano() - epoch + int64(conn.opts.Timeout)
 	}
 
-	shard.rmut.Unlock()
+	conn.stats.sent(fut)
+}
+
+// sendOneway sends request which has no response, ie watch request.
+func (conn *Connection) sendOneway(request request) {
+	fut := allocator.AllocObject[Future]()
+	debugAllocFuture(fut)
+
+	// writer only
+	fut.refs = 1
+	fut.request = request
+
+	conn.queueOf(fut.request.requestId) <- fut
+}
+
+// reject completes future which is not sent to tarantool.
+func (fut *Future) reject(conn *Connection, err error) *Future {
+	fut.err = err
+	// it will never reach writer
+	fut.refs--
+
+	fut.markReady(conn)
 
 	return fut
 }
 
+func (conn *Connection) acquireInFlight(ctx context.Context) error {
+	select {
+	case conn.inflight <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if conn.opts.RLimitAction == RLimitDrop {
+		return ClientError{ErrRateLimited, "too many requests in flight"}
+	}
+
+	select {
+	case conn.inflight <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ClientError{ErrRateLimited, "too many requests in flight: " + ctx.Err().Error()}
+	case <-conn.control:
+		return ClientError{ErrConnectionClosed, "using closed connection"}
+	}
+}
+
 func (fut *Future) markReady(conn *Connection) {
+	if fut.inflight {
+		<-conn.inflight
+	}
+
+	conn.stats.completed(fut)
+
+	fut.complete()
+}
+
+// complete wakes up future consumer and subscribers.
+func (fut *Future) complete() {
+	if atomic.CompareAndSwapUint32(&fut.state, futurePending, futureReady) {
+		fut.ready.Done()
+		fut.unref()
+
+		return
+	}
+
+	futureHooks.Lock()
+	h := futureHooks.m[fut]
+	delete(futureHooks.m, fut)
+	atomic.StoreUint32(&fut.state, futureReady)
+	futureHooks.Unlock()
+
 	fut.ready.Done()
+
+	if h != nil {
+		if h.done != nil {
+			close(h.done)
+		}
+
+		for _, w := range h.waiters {
+			w()
+		}
+
+		if h.callback != nil {
+			// callback may issue new requests, and we can be
+			// called with shard locks held, so run it separately
+			go func() { h.callback(fut.get()) }()
+		}
+	}
+
+	fut.unref()
+}
+
+// hook registers subscriber of future completion.
+// It returns false if future is already completed.
+func (fut *Future) hook(f func(h *hooks)) bool {
+	futureHooks.Lock()
+	defer futureHooks.Unlock()
+
+	h := futureHooks.m[fut]
+	if h == nil {
+		// complete doesn't take the lock while future is pending,
+		// so state is switched only if it hasn't completed yet
+		if !atomic.CompareAndSwapUint32(&fut.state, futurePending, futureHooked) {
+			return false
+		}
+
+		h = &hooks{}
+		futureHooks.m[fut] = h
+	}
+
+	f(h)
+
+	return true
+}
+
+var closedChan = make(chan struct{})
+
+func init() {
+	close(closedChan)
+}
+
+// Done returns a channel that is closed when the future is completed.
+// It does not consume the future: result still should be taken with Get.
+func (fut *Future) Done() <-chan struct{} {
+	var done chan struct{}
+
+	debugCheckFuture(fut)
+
+	ok := fut.hook(func(h *hooks) {
+		if h.done == nil {
+			h.done = make(chan struct{})
+		}
+
+		done = h.done
+	})
+	if !ok {
+		return closedChan
+	}
+
+	return done
+}
+
+// OnComplete registers f to be called with the result of the future.
+// f is called in a separate goroutine and receives exactly what Get would
+// return, so the future is consumed: neither Get nor OnComplete may be
+// called on it afterwards, and f is responsible for releasing the response.
+func (fut *Future) OnComplete(f func(Response, error)) {
+	debugConsumeFuture(fut)
+
+	ok := fut.hook(func(h *hooks) {
+		h.callback = f
+	})
+	if !ok {
+		go func() { f(fut.get()) }()
+	}
+}
+
+// WaitAll waits for all futures to be completed.
+// Futures are not consumed, results should be taken with Get.
+func WaitAll(futs ...*Future) {
+	for _, fut := range futs {
+		<-fut.Done()
+	}
+}
+
+// WaitAny waits for any of futures to be completed and returns its position.
+// Futures are not consumed, results should be taken with Get.
+// It returns -1 if no futures are passed.
+func WaitAny(futs ...*Future) int {
+	if len(futs) == 0 {
+		return -1
+	}
+
+	ch := make(chan int, len(futs))
+
+	for i, fut := range futs {
+		i := i
+
+		debugCheckFuture(fut)
+
+		ok := fut.hook(func(h *hooks) {
+			h.waiters = append(h.waiters, func() { ch <- i })
+		})
+		if !ok {
+			return i
+		}
+	}
+
+	return <-ch
 }
 
 func (fut *Future) fail(conn *Connection, err error) *Future {
@@ -0,0 +1,238 @@
+package tarantool
+
+import (
+	"context"
+
+	"github.com/GoWebProd/gip/allocator"
+)
+
+// Request is a read-only view of request.
+// It is created by New*Request functions and passed to interceptors.
+type Request struct {
+	conn *Connection
+	r    request
+
+	idempotent bool
+}
+
+// Code returns request code, ie SelectRequest.
+func (req Request) Code() int32 {
+	return req.r.requestCode
+}
+
+// Name returns lowercase name of request, ie "select".
+func (req Request) Name() string {
+	return RequestName(req.r.requestCode)
+}
+
+// Space returns space number of data manipulation request.
+func (req Request) Space() uint32 {
+	return req.r.space
+}
+
+// SpaceName returns space name of data manipulation request resolved
+// through connection schema. It is empty if schema is not loaded.
+func (req Request) SpaceName() string {
+	switch req.r.requestCode {
+	case SelectRequest, InsertRequest, ReplaceRequest, UpdateRequest, DeleteRequest, UpsertRequest:
+	default:
+		return ""
+	}
+
+	if req.conn == nil {
+		return ""
+	}
+
+	schema := req.conn.GetSchema()
+	if schema == nil {
+		return ""
+	}
+
+	if space, ok := schema.SpacesById[req.r.space]; ok {
+		return space.Name
+	}
+
+	return ""
+}
+
+// Index returns index number of select, update or delete request.
+func (req Request) Index() uint32 {
+	return req.r.index
+}
+
+// Offset returns offset of select request.
+func (req Request) Offset() uint32 {
+	return req.r.offset
+}
+
+// Limit returns limit of select request.
+func (req Request) Limit() uint32 {
+	return req.r.limit
+}
+
+// Iterator returns iterator of select request.
+func (req Request) Iterator() uint32 {
+	return req.r.iterator
+}
+
+// Key returns key of select, update or delete request.
+func (req Request) Key() Body {
+	switch req.r.requestCode {
+	case SelectRequest, UpdateRequest, DeleteRequest:
+		return req.r.key
+	}
+
+	return nil
+}
+
+// Tuple returns tuple of insert, replace or upsert request.
+func (req Request) Tuple() Body {
+	switch req.r.requestCode {
+	case InsertRequest, ReplaceRequest:
+		return req.r.tuple
+	case UpsertRequest:
+		return req.r.key
+	}
+
+	return nil
+}
+
+// Ops returns operations of update or upsert request.
+func (req Request) Ops() Body {
+	switch req.r.requestCode {
+	case UpdateRequest, UpsertRequest:
+		return req.r.tuple
+	}
+
+	return nil
+}
+
+// Function returns function name of call request or expression of eval request.
+func (req Request) Function() string {
+	return req.r.function
+}
+
+// Args returns arguments of call or eval request.
+func (req Request) Args() Body {
+	switch req.r.requestCode {
+	case CallRequest, Call17Request, EvalRequest:
+		return req.r.tuple
+	}
+
+	return nil
+}
+
+// AsIdempotent returns copy of request marked as idempotent,
+// so it may be retried according to RetryPolicy.
+func (req Request) AsIdempotent() Request {
+	req.idempotent = true
+
+	return req
+}
+
+// IsIdempotent returns true if request may be performed more than once:
+// select and ping requests always, other ones if marked with AsIdempotent.
+func (req Request) IsIdempotent() bool {
+	switch req.r.requestCode {
+	case SelectRequest, PingRequest:
+		return true
+	}
+
+	return req.idempotent
+}
+
+// Invoker performs request and returns its result.
+type Invoker func(ctx context.Context, req Request) (Response, error)
+
+// Interceptor wraps performing of requests by Connection.
+// It may inspect request, context and result, and must call next
+// to actually perform request.
+type Interceptor func(ctx context.Context, req Request, next Invoker) (Response, error)
+
+// Do performs request through interceptors and returns its result.
+// If ctx is done before response is received, ctx.Err() is returned.
+func (conn *Connection) Do(ctx context.Context, req Request) (Response, error) {
+	req.conn = conn
+
+	return conn.invoker(ctx, req)
+}
+
+// DoAsync sends request through interceptors and returns Future.
+// Without interceptors and retries ctx is used only while request is sent,
+// ie when waiting for MaxInFlight slot.
+func (conn *Connection) DoAsync(ctx context.Context, req Request) *Future {
+	if len(conn.opts.Interceptors) == 0 && !conn.retries(ctx, req) {
+		if err := conn.waitReady(ctx); err != nil {
+			fut := newProxyFuture(req)
+			fut.err = err
+			fut.complete()
+
+			return fut
+		}
+
+		return conn.newFutureContext(ctx, req.r)
+	}
+
+	req.conn = conn
+
+	// interceptors and retries are synchronous, so future is completed
+	// by separate goroutine when they return
+	fut := newProxyFuture(req)
+
+	go func() {
+		resp, err := conn.invoker(ctx, req)
+
+		fut.request.requestId = resp.RequestId
+		fut.resp = resp
+		fut.err = err
+
+		fut.complete()
+	}()
+
+	return fut
+}
+
+// newProxyFuture creates future which is not sent by itself,
+// it is completed with result of request performed elsewhere.
+func newProxyFuture(req Request) *Future {
+	fut := allocator.AllocObject[Future]()
+	debugAllocFuture(fut)
+
+	// consumer and completion
+	fut.refs = 2
+	fut.request = req.r
+
+	return fut
+}
+
+func (conn *Connection) buildInvoker() {
+	conn.invoker = conn.retry(conn.invoke)
+
+	for i := len(conn.opts.Interceptors) - 1; i >= 0; i-- {
+		interceptor, next := conn.opts.Interceptors[i], conn.invoker
+
+		conn.invoker = func(ctx context.Context, req Request) (Response, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+}
+
+func (conn *Connection) invoke(ctx context.Context, req Request) (Response, error) {
+	if err := conn.waitReady(ctx); err != nil {
+		return Response{}, err
+	}
+
+	fut := conn.newFutureContext(ctx, req.r)
+
+	if done := ctx.Done(); done != nil {
+		select {
+		case <-fut.Done():
+		case <-done:
+			fut.Release()
+
+			return Response{}, ctx.Err()
+		}
+	}
+
+	return fut.Get()
+}
@@ -0,0 +1,262 @@
+package tarantool
+
+import (
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+)
+
+// IntervalExtension is msgpack extension type of tarantool interval.
+const IntervalExtension = 6
+
+// Adjust is a mode of adding months and years to the last days of month.
+// Its values differ from values sent to tarantool, so zero value is
+// NoneAdjust as default adjust of tarantool intervals.
+type Adjust int64
+
+const (
+	// NoneAdjust truncates day to the last day of month (adjust = "none").
+	NoneAdjust Adjust = 0
+	// ExcessAdjust overflows day to the next month (adjust = "excess").
+	ExcessAdjust Adjust = 1
+	// LastAdjust keeps the last day of month (adjust = "last").
+	LastAdjust Adjust = 2
+)
+
+// adjust values of c-dt sent to tarantool
+const (
+	dtExcess = 0
+	dtLimit  = 1
+	dtSnap   = 2
+)
+
+func (a Adjust) dt() int64 {
+	switch a {
+	case ExcessAdjust:
+		return dtExcess
+	case LastAdjust:
+		return dtSnap
+	default:
+		return dtLimit
+	}
+}
+
+func adjustOf(dt int64) Adjust {
+	switch dt {
+	case dtExcess:
+		return ExcessAdjust
+	case dtSnap:
+		return LastAdjust
+	default:
+		return NoneAdjust
+	}
+}
+
+// interval fields in order of their ids
+const (
+	intervalYear = iota
+	intervalMonth
+	intervalWeek
+	intervalDay
+	intervalHour
+	intervalMin
+	intervalSec
+	intervalNsec
+	intervalAdjust
+	intervalFields
+)
+
+// Interval is tarantool datetime interval.
+type Interval struct {
+	Year   int64
+	Month  int64
+	Week   int64
+	Day    int64
+	Hour   int64
+	Min    int64
+	Sec    int64
+	Nsec   int64
+	Adjust Adjust
+}
+
+// fields returns values of fields sent to tarantool.
+func (ival *Interval) fields() [intervalFields]int64 {
+	return [intervalFields]int64{
+		ival.Year, ival.Month, ival.Week, ival.Day,
+		ival.Hour, ival.Min, ival.Sec, ival.Nsec, ival.Adjust.dt(),
+	}
+}
+
+// encoded reports whether field is encoded. Adjust is always encoded,
+// so interval doesn't depend on default adjust of receiver.
+func encoded(id int, v int64) bool {
+	return v != 0 || id == intervalAdjust
+}
+
+// Add returns sum of intervals, adjust is taken from ival.
+func (ival Interval) Add(other Interval) Interval {
+	ival.Year += other.Year
+	ival.Month += other.Month
+	ival.Week += other.Week
+	ival.Day += other.Day
+	ival.Hour += other.Hour
+	ival.Min += other.Min
+	ival.Sec += other.Sec
+	ival.Nsec += other.Nsec
+
+	return ival
+}
+
+// Sub returns difference of intervals, adjust is taken from ival.
+func (ival Interval) Sub(other Interval) Interval {
+	return ival.Add(other.Neg())
+}
+
+// Neg returns interval with negated fields.
+func (ival Interval) Neg() Interval {
+	return Interval{
+		Year:   -ival.Year,
+		Month:  -ival.Month,
+		Week:   -ival.Week,
+		Day:    -ival.Day,
+		Hour:   -ival.Hour,
+		Min:    -ival.Min,
+		Sec:    -ival.Sec,
+		Nsec:   -ival.Nsec,
+		Adjust: ival.Adjust,
+	}
+}
+
+// ExtensionType implements msgp.Extension.
+func (ival *Interval) ExtensionType() int8 {
+	return IntervalExtension
+}
+
+// Len implements msgp.Extension.
+func (ival *Interval) Len() int {
+	l := 1
+
+	for id, v := range ival.fields() {
+		if encoded(id, v) {
+			l += msgp.IntSize(uint64(id)) + intervalValueSize(v)
+		}
+	}
+
+	return l
+}
+
+func intervalValueSize(v int64) int {
+	if v >= 0 {
+		return msgp.IntSize(uint64(v))
+	}
+
+	return len(msgp.AppendInt64(nil, v))
+}
+
+// MarshalBinaryTo implements msgp.Extension.
+func (ival *Interval) MarshalBinaryTo(b []byte) error {
+	fields := ival.fields()
+
+	count := 0
+	for id, v := range fields {
+		if encoded(id, v) {
+			count++
+		}
+	}
+
+	b = append(b[:0], byte(count))
+
+	for id, v := range fields {
+		if !encoded(id, v) {
+			continue
+		}
+
+		b = msgp.AppendUint64(b, uint64(id))
+
+		if v >= 0 {
+			b = msgp.AppendUint64(b, uint64(v))
+		} else {
+			b = msgp.AppendInt64(b, v)
+		}
+	}
+
+	return nil
+}
+
+// UnmarshalBinary implements msgp.Extension.
+func (ival *Interval) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 {
+		return errors.New("can't decode interval: no fields count")
+	}
+
+	var fields [intervalFields]int64
+
+	// tarantool doesn't encode default adjust
+	fields[intervalAdjust] = dtLimit
+
+	count, remain := int(b[0]), b[1:]
+
+	for i := 0; i < count; i++ {
+		var (
+			id  uint64
+			v   int64
+			err error
+		)
+
+		if id, remain, err = msgp.ReadUint64Bytes(remain); err != nil {
+			return errors.Wrap(err, "can't decode interval field")
+		}
+
+		if v, remain, err = msgp.ReadInt64Bytes(remain); err != nil {
+			return errors.Wrap(err, "can't decode interval value")
+		}
+
+		if id >= intervalFields {
+			return errors.Errorf("can't decode interval: unknown field %d", id)
+		}
+
+		fields[id] = v
+	}
+
+	*ival = Interval{
+		Year:   fields[intervalYear],
+		Month:  fields[intervalMonth],
+		Week:   fields[intervalWeek],
+		Day:    fields[intervalDay],
+		Hour:   fields[intervalHour],
+		Min:    fields[intervalMin],
+		Sec:    fields[intervalSec],
+		Nsec:   fields[intervalNsec],
+		Adjust: adjustOf(fields[intervalAdjust]),
+	}
+
+	return nil
+}
+
+// EncodeMsg implements msgp.Encodable.
+func (ival *Interval) EncodeMsg(w *msgp.Writer) error {
+	return w.WriteExtension(ival)
+}
+
+// DecodeMsg implements msgp.Decodable.
+func (ival *Interval) DecodeMsg(r *msgp.Reader) error {
+	return r.ReadExtension(ival)
+}
+
+// MarshalMsg implements msgp.Marshaler.
+func (ival *Interval) MarshalMsg(b []byte) ([]byte, error) {
+	return msgp.AppendExtension(b, ival)
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler.
+func (ival *Interval) UnmarshalMsg(b []byte) ([]byte, error) {
+	return msgp.ReadExtensionBytes(b, ival)
+}
+
+// Msgsize implements msgp.Sizer.
+func (ival *Interval) Msgsize() int {
+	return extensionSize(ival.Len())
+}
+
+func init() {
+	msgp.RegisterExtension(IntervalExtension, func() msgp.Extension { return new(Interval) })
+}
@@ -12,17 +12,19 @@ import (
 	"github.com/pkg/errors"
 )
 
-func (conn *Connection) writer(w *bufio.Writer, c net.Conn) {
+// writer sends requests from socket queue.
+// c is the first socket of connection, it is used to detect stale failures.
+func (conn *Connection) writer(w *bufio.Writer, s *socket, c net.Conn) {
 	var future *Future
 
 	writer := msgp.NewWriter(w)
 
 	for atomic.LoadUint32(&conn.state) != connClosed {
 		select {
-		case future = <-conn.queue:
+		case future = <-s.queue:
 		default:
 			runtime.Gosched()
-			if len(conn.queue) == 0 {
+			if len(s.queue) == 0 {
 				if err := w.Flush(); err != nil {
 					conn.reconnect(err, c)
 
@@ -30,13 +32,23 @@ func (conn *Connection) writer(w *bufio.Writer, c net.Conn) {
 				}
 			}
 			select {
-			case future = <-conn.queue:
+			case future = <-s.queue:
 			case <-conn.control:
 				return
 			}
 		}
 
-		if err := future.write(w, writer); err != nil {
+		if atomic.LoadUint32(&future.state) == futureReady {
+			// already failed by timeout or disconnect
+			future.unref()
+
+			continue
+		}
+
+		err := future.write(w, writer)
+		future.unref()
+
+		if err != nil {
 			conn.reconnect(err, c)
 
 			return
@@ -44,9 +56,9 @@ func (conn *Connection) writer(w *bufio.Writer, c net.Conn) {
 	}
 }
 
-func (conn *Connection) reader(r *bufio.Reader, c net.Conn) {
+func (conn *Connection) reader(r *bufio.Reader, s *socket, c net.Conn) {
 	for atomic.LoadUint32(&conn.state) != connClosed {
-		respBytes, err := conn.read(r)
+		respBytes, err := conn.read(r, s.lenBuf[:])
 		if err != nil {
 			conn.reconnect(err, c)
 
@@ -63,35 +75,52 @@ func (conn *Connection) reader(r *bufio.Reader, c net.Conn) {
 			return
 		}
 
+		if resp.Code == EventRequest {
+			conn.event(&resp, c)
+			resp.Release()
+
+			continue
+		}
+
 		if fut := conn.fetchFuture(resp.RequestId); fut != nil {
 			fut.resp = resp
 
 			fut.markReady(conn)
 		} else {
+			// request id 0 is used by requests without response, ie watch
+			if resp.RequestId != 0 {
+				conn.opts.Logger.Warn("tarantool: response to unknown request", "addr", conn.addr,
+					"request_id", resp.RequestId, "code", resp.Code)
+			}
+
 			resp.Release()
 		}
 	}
 }
 
-func (conn *Connection) read(r io.Reader) ([]byte, error) {
+func (conn *Connection) read(r io.Reader, lenBuf []byte) ([]byte, error) {
 	var length int
 
-	if _, err := io.ReadFull(r, conn.lenBuf[:]); err != nil {
+	if _, err := io.ReadFull(r, lenBuf[:5]); err != nil {
 		return nil, errors.Wrap(err, "read response header error")
 	}
 
-	if conn.lenBuf[0] != 0xce {
+	if lenBuf[0] != 0xce {
 		return nil, errors.New("wrong reponse header")
 	}
 
-	length = (int(conn.lenBuf[1]) << 24) + (int(conn.lenBuf[2]) << 16) + (int(conn.lenBuf[3]) << 8) + int(conn.lenBuf[4])
+	length = (int(lenBuf[1]) << 24) + (int(lenBuf[2]) << 16) + (int(lenBuf[3]) << 8) + int(lenBuf[4])
 	if length == 0 {
 		return nil, errors.New("response should not be 0 length")
 	}
 
 	response := allocator.Alloc(length)
+	debugAllocBuffer(response)
 
 	if _, err := io.ReadFull(r, response); err != nil {
+		resp := Response{buf: response}
+		resp.Release()
+
 		return nil, errors.Wrap(err, "read response body error")
 	}
 
@@ -0,0 +1,67 @@
+package tarantool
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// lazyInit loads schema when lazy connection is established
+// and marks connection ready. It is run after every dial,
+// but does nothing when connection is ready already.
+func (conn *Connection) lazyInit() {
+	if !atomic.CompareAndSwapUint32(&conn.initializing, 0, 1) {
+		return
+	}
+	defer atomic.StoreUint32(&conn.initializing, 0)
+
+	select {
+	case <-conn.ready:
+		return
+	default:
+	}
+
+	if !conn.opts.SkipSchema {
+		if err := conn.loadSchema(); err != nil {
+			// will be retried on next connection
+			conn.opts.Logger.Error("tarantool: schema loading failed", "addr", conn.addr, "error", err)
+
+			return
+		}
+	}
+
+	close(conn.ready)
+}
+
+// waitReady waits for lazy connection to be ready.
+func (conn *Connection) waitReady(ctx context.Context) error {
+	if conn.ready == nil {
+		return nil
+	}
+
+	select {
+	case <-conn.ready:
+		return nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+
+	if _, ok := ctx.Deadline(); !ok && conn.opts.Timeout > 0 {
+		t := time.NewTimer(conn.opts.Timeout)
+		defer t.Stop()
+
+		timeout = t.C
+	}
+
+	select {
+	case <-conn.ready:
+		return nil
+	case <-ctx.Done():
+		return ClientError{ErrConnectionNotReady, "client connection is not ready: " + ctx.Err().Error()}
+	case <-timeout:
+		return ClientError{ErrConnectionNotReady, "client connection is not ready"}
+	case <-conn.control:
+		return ClientError{ErrConnectionClosed, "using closed connection"}
+	}
+}
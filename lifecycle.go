@@ -0,0 +1,28 @@
+//go:build !tarantool_debug
+
+package tarantool
+
+// Lifecycle checks are compiled in only with tarantool_debug build tag.
+// See lifecycle_debug.go.
+
+func debugAllocFuture(fut *Future) {}
+
+func debugCheckFuture(fut *Future) {}
+
+func debugConsumeFuture(fut *Future) {}
+
+func debugFreeFuture(fut *Future) bool {
+	return true
+}
+
+func debugAllocBuffer(buf []byte) {}
+
+func debugFreeBuffer(buf []byte) bool {
+	return true
+}
+
+// Leaks returns allocation stacks of futures and responses which are
+// not released yet. It always returns nil without tarantool_debug build tag.
+func Leaks() []string {
+	return nil
+}
@@ -0,0 +1,193 @@
+//go:build tarantool_debug
+
+package tarantool
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"unsafe"
+
+	"github.com/GoWebProd/gip/allocator"
+)
+
+// With tarantool_debug build tag futures and response buffers are tracked:
+// released objects are poisoned and kept in quarantine for a while,
+// so any use after release or double release panics with stack traces
+// of both allocation and release.
+
+const (
+	quarantineSize = 4096
+	poisonByte     = 0xc1 // never used in msgpack
+)
+
+type lifecycleRecord struct {
+	alloc    []byte
+	consumed []byte
+	release  []byte
+}
+
+type quarantined struct {
+	fut *Future
+	buf []byte
+}
+
+var lifecycle = struct {
+	sync.Mutex
+	futures    map[*Future]*lifecycleRecord
+	buffers    map[*byte]*lifecycleRecord
+	released   map[unsafe.Pointer]*lifecycleRecord
+	quarantine []quarantined
+}{
+	futures:  make(map[*Future]*lifecycleRecord),
+	buffers:  make(map[*byte]*lifecycleRecord),
+	released: make(map[unsafe.Pointer]*lifecycleRecord),
+}
+
+func debugAllocFuture(fut *Future) {
+	lifecycle.Lock()
+	lifecycle.futures[fut] = &lifecycleRecord{alloc: debug.Stack()}
+	lifecycle.Unlock()
+}
+
+func debugCheckFuture(fut *Future) {
+	lifecycle.Lock()
+	defer lifecycle.Unlock()
+
+	rec := lifecycleFuture(fut, "used")
+	if rec != nil && rec.consumed != nil {
+		panic(fmt.Sprintf("tarantool: future used after consume\n\nallocated at:\n%s\nconsumed at:\n%s", rec.alloc, rec.consumed))
+	}
+}
+
+func debugConsumeFuture(fut *Future) {
+	lifecycle.Lock()
+	defer lifecycle.Unlock()
+
+	rec := lifecycleFuture(fut, "consumed")
+	if rec == nil {
+		return
+	}
+
+	if rec.consumed != nil {
+		panic(fmt.Sprintf("tarantool: future consumed twice\n\nallocated at:\n%s\nconsumed at:\n%s", rec.alloc, rec.consumed))
+	}
+
+	rec.consumed = debug.Stack()
+}
+
+func debugFreeFuture(fut *Future) bool {
+	lifecycle.Lock()
+	defer lifecycle.Unlock()
+
+	rec := lifecycleFuture(fut, "released")
+	if rec == nil {
+		return true
+	}
+
+	delete(lifecycle.futures, fut)
+
+	rec.release = debug.Stack()
+	lifecycle.released[unsafe.Pointer(fut)] = rec
+
+	poison(unsafe.Slice((*byte)(unsafe.Pointer(fut)), unsafe.Sizeof(*fut)))
+	lifecycleQuarantine(quarantined{fut: fut})
+
+	return false
+}
+
+func debugAllocBuffer(buf []byte) {
+	if len(buf) == 0 {
+		return
+	}
+
+	lifecycle.Lock()
+	lifecycle.buffers[&buf[0]] = &lifecycleRecord{alloc: debug.Stack()}
+	delete(lifecycle.released, unsafe.Pointer(&buf[0]))
+	lifecycle.Unlock()
+}
+
+func debugFreeBuffer(buf []byte) bool {
+	if len(buf) == 0 {
+		return true
+	}
+
+	lifecycle.Lock()
+	defer lifecycle.Unlock()
+
+	rec, ok := lifecycle.buffers[&buf[0]]
+	if !ok {
+		if rec, ok = lifecycle.released[unsafe.Pointer(&buf[0])]; ok {
+			panic(fmt.Sprintf("tarantool: response released twice\n\nallocated at:\n%s\nreleased at:\n%s", rec.alloc, rec.release))
+		}
+
+		// not allocated by connection
+		return true
+	}
+
+	delete(lifecycle.buffers, &buf[0])
+
+	rec.release = debug.Stack()
+	lifecycle.released[unsafe.Pointer(&buf[0])] = rec
+
+	poison(buf)
+	lifecycleQuarantine(quarantined{buf: buf})
+
+	return false
+}
+
+// Leaks returns allocation stacks of futures and responses which are
+// not released yet. It always returns nil without tarantool_debug build tag.
+func Leaks() []string {
+	lifecycle.Lock()
+	defer lifecycle.Unlock()
+
+	var leaks []string
+
+	for _, rec := range lifecycle.futures {
+		leaks = append(leaks, fmt.Sprintf("future allocated at:\n%s", rec.alloc))
+	}
+
+	for _, rec := range lifecycle.buffers {
+		leaks = append(leaks, fmt.Sprintf("response allocated at:\n%s", rec.alloc))
+	}
+
+	return leaks
+}
+
+func lifecycleFuture(fut *Future, action string) *lifecycleRecord {
+	if rec, ok := lifecycle.futures[fut]; ok {
+		return rec
+	}
+
+	if rec, ok := lifecycle.released[unsafe.Pointer(fut)]; ok {
+		panic(fmt.Sprintf("tarantool: future %s after release\n\nallocated at:\n%s\nreleased at:\n%s", action, rec.alloc, rec.release))
+	}
+
+	// not allocated by connection, ie auth request
+	return nil
+}
+
+func lifecycleQuarantine(q quarantined) {
+	lifecycle.quarantine = append(lifecycle.quarantine, q)
+	if len(lifecycle.quarantine) <= quarantineSize {
+		return
+	}
+
+	old := lifecycle.quarantine[0]
+	lifecycle.quarantine = lifecycle.quarantine[1:]
+
+	if old.fut != nil {
+		delete(lifecycle.released, unsafe.Pointer(old.fut))
+		allocator.FreeObject(old.fut)
+	} else {
+		delete(lifecycle.released, unsafe.Pointer(&old.buf[0]))
+		allocator.Free(old.buf)
+	}
+}
+
+func poison(b []byte) {
+	for i := range b {
+		b[i] = poisonByte
+	}
+}
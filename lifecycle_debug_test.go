@@ -0,0 +1,74 @@
+//go:build tarantool_debug
+
+package tarantool
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/GoWebProd/gip/allocator"
+)
+
+func expectPanic(t *testing.T, substr string, f func()) {
+	t.Helper()
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatalf("expected panic with %q", substr)
+		}
+
+		if msg, ok := r.(string); !ok || !strings.Contains(msg, substr) {
+			t.Fatalf("unexpected panic: %v", r)
+		}
+	}()
+
+	f()
+}
+
+func TestLifecycleFuture(t *testing.T) {
+	conn := &Connection{
+		opts:  Opts{Concurrency: 1},
+		shard: make([]connShard, 1),
+		state: connClosed,
+	}
+
+	fut := conn.newFuture(request{requestCode: PingRequest})
+	if _, err := fut.Get(); err == nil {
+		t.Fatalf("expected error for closed connection")
+	}
+
+	expectPanic(t, "future consumed after release", func() { fut.Get() })
+	expectPanic(t, "future used after release", func() { fut.Done() })
+	expectPanic(t, "future consumed after release", func() { fut.Release() })
+}
+
+func TestLifecycleResponse(t *testing.T) {
+	leaks := len(Leaks())
+
+	buf := allocator.Alloc(16)
+	debugAllocBuffer(buf)
+
+	if len(Leaks()) != leaks+1 {
+		t.Fatalf("response leak is not reported")
+	}
+
+	resp := Response{buf: buf, Data: buf}
+	copied := resp
+
+	resp.Release()
+
+	if resp.Data != nil {
+		t.Fatalf("Data is not reset after Release")
+	}
+
+	if copied.Data[0] != poisonByte {
+		t.Fatalf("released response is not poisoned")
+	}
+
+	expectPanic(t, "response released twice", func() { copied.Release() })
+
+	if len(Leaks()) != leaks {
+		t.Fatalf("released response is reported as leak")
+	}
+}
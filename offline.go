@@ -0,0 +1,132 @@
+package tarantool
+
+import (
+	"time"
+
+	"github.com/GoWebProd/gip/fasttime"
+	"github.com/GoWebProd/gip/spinlock"
+)
+
+// offlineQueue keeps requests created while connection is not ready,
+// they are sent when connection is reestablished.
+type offlineQueue struct {
+	mut  spinlock.Locker
+	futs []*Future
+}
+
+func (conn *Connection) offlineTimeout() time.Duration {
+	switch {
+	case conn.opts.OfflineTimeout > 0:
+		return conn.opts.OfflineTimeout
+	case conn.opts.Timeout > 0:
+		return conn.opts.Timeout
+	default:
+		return time.Second
+	}
+}
+
+// bufferOffline keeps future until connection is reestablished.
+// It must be called with shard lock held, so dial can't miss it.
+func (conn *Connection) bufferOffline(fut *Future) bool {
+	if conn.opts.OfflineQueue == 0 {
+		return false
+	}
+
+	q := &conn.offline
+
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	if uint32(len(q.futs)) >= conn.opts.OfflineQueue {
+		return false
+	}
+
+	fut.timeout = fasttime.NowNano() - epoch + int64(conn.offlineTimeout())
+	q.futs = append(q.futs, fut)
+
+	return true
+}
+
+// flushOffline moves buffered futures to shards and returns
+// ones to be sent. It must be called with all shard locks held.
+func (conn *Connection) flushOffline() []*Future {
+	q := &conn.offline
+
+	q.mut.Lock()
+	futs := q.futs
+	q.futs = nil
+	q.mut.Unlock()
+
+	now := fasttime.NowNano() - epoch
+	send := futs[:0]
+
+	for _, fut := range futs {
+		if fut.timeout < now {
+			fut.reject(conn, errOfflineTimeout)
+
+			continue
+		}
+
+		conn.putFuture(&conn.shard[fut.request.requestId&(conn.opts.Concurrency-1)], fut)
+
+		send = append(send, fut)
+	}
+
+	return send
+}
+
+// failOffline fails all buffered futures with err.
+func (conn *Connection) failOffline(err error) {
+	q := &conn.offline
+
+	q.mut.Lock()
+	futs := q.futs
+	q.futs = nil
+	q.mut.Unlock()
+
+	for _, fut := range futs {
+		fut.reject(conn, err)
+	}
+}
+
+var errOfflineTimeout = ClientError{ErrTimeouted, "client timeout while connection is not ready"}
+
+// offlineTimeouts fails buffered futures which are not sent in OfflineTimeout.
+func (conn *Connection) offlineTimeouts() {
+	interval := conn.offlineTimeout() / 10
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	q := &conn.offline
+
+	for {
+		select {
+		case <-conn.control:
+			return
+		case <-t.C:
+		}
+
+		now := fasttime.NowNano() - epoch
+
+		q.mut.Lock()
+
+		// futures are buffered in order of their deadlines
+		n := 0
+		for n < len(q.futs) && q.futs[n].timeout < now {
+			n++
+		}
+
+		expired := append([]*Future(nil), q.futs[:n]...)
+		q.futs = append(q.futs[:0], q.futs[n:]...)
+
+		q.mut.Unlock()
+
+		for _, fut := range expired {
+			fut.reject(conn, errOfflineTimeout)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package prometheus exposes tarantool.Connection statistics
+// in Prometheus text exposition format.
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+const namespace = "tarantool_client"
+
+// Collector collects statistics of registered connections.
+// It implements http.Handler, so it can be mounted as metrics endpoint,
+// or it can be written to another exporter with WriteTo.
+type Collector struct {
+	mutex sync.Mutex
+	conns map[string]*tarantool.Connection
+}
+
+// New creates empty Collector.
+func New() *Collector {
+	return &Collector{
+		conns: make(map[string]*tarantool.Connection),
+	}
+}
+
+// Register adds connection to collector.
+// Name is exported as "conn" label of every metric.
+func (c *Collector) Register(name string, conn *tarantool.Connection) {
+	c.mutex.Lock()
+	c.conns[name] = conn
+	c.mutex.Unlock()
+}
+
+// Unregister removes connection from collector.
+func (c *Collector) Unregister(name string) {
+	c.mutex.Lock()
+	delete(c.conns, name)
+	c.mutex.Unlock()
+}
+
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	c.WriteTo(w)
+}
+
+type sample struct {
+	conn  string
+	stats tarantool.Stats
+}
+
+// WriteTo writes statistics of registered connections in Prometheus text format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mutex.Lock()
+
+	samples := make([]sample, 0, len(c.conns))
+	for name, conn := range c.conns {
+		samples = append(samples, sample{name, conn.Stats()})
+	}
+
+	c.mutex.Unlock()
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i].conn < samples[j].conn })
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	cw.header("requests_total", "counter", "Amount of sent requests.")
+	for _, s := range samples {
+		for _, code := range sortedCodes(s.stats.Requests) {
+			cw.metric("requests_total", labels(s.conn, "request", tarantool.RequestName(code)), s.stats.Requests[code])
+		}
+	}
+
+	cw.header("errors_total", "counter", "Amount of failed requests by error code.")
+	for _, s := range samples {
+		codes := make([]uint32, 0, len(s.stats.Errors))
+		for code := range s.stats.Errors {
+			codes = append(codes, code)
+		}
+
+		sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+
+		for _, code := range codes {
+			cw.metric("errors_total", labels(s.conn, "code", strconv.FormatUint(uint64(code), 10)), s.stats.Errors[code])
+		}
+	}
+
+	cw.header("request_duration_seconds", "histogram", "Latency of requests.")
+	for _, s := range samples {
+		for _, code := range sortedCodes(s.stats.Latency) {
+			h := s.stats.Latency[code]
+			l := labels(s.conn, "request", tarantool.RequestName(code))
+
+			for i, le := range h.Buckets {
+				cw.metric("request_duration_seconds_bucket", l+`,le="`+strconv.FormatFloat(le.Seconds(), 'g', -1, 64)+`"`, h.Counts[i])
+			}
+
+			cw.metric("request_duration_seconds_bucket", l+`,le="+Inf"`, h.Count)
+			cw.metric("request_duration_seconds_sum", l, h.Sum.Seconds())
+			cw.metric("request_duration_seconds_count", l, h.Count)
+		}
+	}
+
+	cw.header("inflight_requests", "gauge", "Amount of requests waiting for response.")
+	for _, s := range samples {
+		cw.metric("inflight_requests", labels(s.conn), s.stats.InFlight)
+	}
+
+	cw.header("queue_length", "gauge", "Amount of requests waiting to be written.")
+	for _, s := range samples {
+		cw.metric("queue_length", labels(s.conn), s.stats.QueueDepth)
+	}
+
+	cw.header("reconnects_total", "counter", "Amount of successful reconnects.")
+	for _, s := range samples {
+		cw.metric("reconnects_total", labels(s.conn), s.stats.Reconnects)
+	}
+
+	cw.header("read_bytes_total", "counter", "Amount of bytes read from socket.")
+	for _, s := range samples {
+		cw.metric("read_bytes_total", labels(s.conn), s.stats.BytesRead)
+	}
+
+	cw.header("written_bytes_total", "counter", "Amount of bytes written to socket.")
+	for _, s := range samples {
+		cw.metric("written_bytes_total", labels(s.conn), s.stats.BytesWritten)
+	}
+
+	cw.header("timeouts_total", "counter", "Amount of requests failed by client timeout.")
+	for _, s := range samples {
+		cw.metric("timeouts_total", labels(s.conn), s.stats.Timeouts)
+	}
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+
+	return cw.n, cw.err
+}
+
+func sortedCodes[T any](m map[int32]T) []int32 {
+	codes := make([]int32, 0, len(m))
+	for code := range m {
+		codes = append(codes, code)
+	}
+
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+
+	return codes
+}
+
+func labels(conn string, pairs ...string) string {
+	l := "conn=" + strconv.Quote(conn)
+
+	for i := 0; i+1 < len(pairs); i += 2 {
+		l += "," + pairs[i] + "=" + strconv.Quote(pairs[i+1])
+	}
+
+	return l
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) printf(format string, args ...interface{}) {
+	if cw.err != nil {
+		return
+	}
+
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+func (cw *countingWriter) header(name, typ, help string) {
+	cw.printf("# HELP %s_%s %s\n# TYPE %s_%s %s\n", namespace, name, help, namespace, name, typ)
+}
+
+func (cw *countingWriter) metric(name, labels string, value interface{}) {
+	cw.printf("%s_%s{%s} %v\n", namespace, name, labels, value)
+}
@@ -0,0 +1,54 @@
+package prometheus
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+func TestCollector(t *testing.T) {
+	// nobody listens there, connection will be reconnecting in background
+	conn, err := tarantool.Connect("127.0.0.1:1", tarantool.Opts{
+		Reconnect:  time.Hour,
+		SkipSchema: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create connection: %s", err.Error())
+	}
+	defer conn.Close()
+
+	if _, err = conn.Ping(); err == nil {
+		t.Fatalf("Ping of not connected connection should fail")
+	}
+
+	c := New()
+	c.Register("test", conn)
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := rec.Body.String()
+
+	for _, line := range []string{
+		"# TYPE tarantool_client_request_duration_seconds histogram",
+		`tarantool_client_errors_total{conn="test",code="16384"} 1`,
+		`tarantool_client_inflight_requests{conn="test"} 0`,
+		`tarantool_client_reconnects_total{conn="test"} 0`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Fatalf("metrics do not contain %q:\n%s", line, body)
+		}
+	}
+
+	c.Unregister("test")
+
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if strings.Contains(rec.Body.String(), `conn="test"`) {
+		t.Fatalf("unregistered connection is exported")
+	}
+}
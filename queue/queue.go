@@ -0,0 +1,391 @@
+// Package queue is client of tarantool/queue module. Tube methods call
+// queue functions, ie "queue.tube.<name>:put", and decode returned tasks:
+//
+//	tube, err := queue.Create(ctx, conn, "mail", queue.FIFOTTL, queue.CreateOpts{IfNotExists: true})
+//
+//	_, err = tube.Put(ctx, map[string]interface{}{"to": "user@example.com"}, queue.PutOpts{TTL: time.Hour})
+//
+//	task, err := tube.Take(ctx, time.Second)
+//	if task != nil {
+//		// process task.Data
+//		_, err = tube.Ack(ctx, task.ID)
+//	}
+//
+// Data of tasks is encoded with msgp.AppendIntf and decoded with tarantool.Decode.
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+// Type is type of tube.
+type Type string
+
+const (
+	FIFO       Type = "fifo"
+	FIFOTTL    Type = "fifottl"
+	UTube      Type = "utube"
+	UTubeTTL   Type = "utubettl"
+	LimFIFOTTL Type = "limfifottl"
+)
+
+// Status is status of task.
+type Status string
+
+const (
+	Ready   Status = "r"
+	Taken   Status = "t"
+	Done    Status = "-"
+	Buried  Status = "!"
+	Delayed Status = "~"
+)
+
+// Task is task of tube.
+type Task struct {
+	ID     uint64
+	Status Status
+	Data   interface{}
+}
+
+// CreateOpts are options of tube creation.
+type CreateOpts struct {
+	// Temporary tube is not persisted.
+	Temporary bool
+	// IfNotExists makes creation of existing tube succeed.
+	IfNotExists bool
+	// Capacity limits number of tasks of limfifottl tube.
+	Capacity uint64
+	// Engine is engine of tube space, memtx by default.
+	Engine string
+}
+
+// PutOpts are options of put. Zero values are not sent, so defaults
+// of tube are used.
+type PutOpts struct {
+	// TTL is time to live of task, it is deleted after TTL.
+	TTL time.Duration
+	// TTR is time to run: taken task is released after TTR.
+	TTR time.Duration
+	// Delay postpones readiness of task.
+	Delay time.Duration
+	// Pri is priority of task, lower value is higher priority.
+	Pri uint64
+	// UTube is name of subqueue of utube and utubettl tubes.
+	UTube string
+}
+
+func (opts PutOpts) values() map[string]interface{} {
+	values := make(map[string]interface{})
+
+	if opts.TTL > 0 {
+		values["ttl"] = opts.TTL.Seconds()
+	}
+
+	if opts.TTR > 0 {
+		values["ttr"] = opts.TTR.Seconds()
+	}
+
+	if opts.Delay > 0 {
+		values["delay"] = opts.Delay.Seconds()
+	}
+
+	if opts.Pri > 0 {
+		values["pri"] = opts.Pri
+	}
+
+	if opts.UTube != "" {
+		values["utube"] = opts.UTube
+	}
+
+	return values
+}
+
+// ReleaseOpts are options of release.
+type ReleaseOpts struct {
+	// Delay postpones readiness of released task.
+	Delay time.Duration
+}
+
+// TaskStatistics are numbers of tasks by status.
+type TaskStatistics struct {
+	Taken   uint64
+	Buried  uint64
+	Ready   uint64
+	Done    uint64
+	Delayed uint64
+	Total   uint64
+}
+
+// Statistics are statistics of tube.
+type Statistics struct {
+	Tasks TaskStatistics
+	// Calls are numbers of calls by method name, ie "put".
+	Calls map[string]uint64
+}
+
+// createExpr creates tube with arguments name, type and options.
+const createExpr = "queue.create_tube(...)"
+
+// Tube is tube of queue.
+type Tube struct {
+	conn *tarantool.Connection
+	name string
+}
+
+// Create creates tube.
+func Create(ctx context.Context, conn *tarantool.Connection, name string, typ Type, opts CreateOpts) (*Tube, error) {
+	values := map[string]interface{}{
+		"temporary":     opts.Temporary,
+		"if_not_exists": opts.IfNotExists,
+	}
+
+	if opts.Capacity > 0 {
+		values["capacity"] = opts.Capacity
+	}
+
+	if opts.Engine != "" {
+		values["engine"] = opts.Engine
+	}
+
+	args, err := msgp.AppendIntf(nil, []interface{}{name, string(typ), values})
+	if err != nil {
+		return nil, errors.Wrap(err, "can't encode options")
+	}
+
+	// tube object has functions, so it can't be returned by call
+	if _, err = do(ctx, conn, tarantool.NewEvalRequest(createExpr, msgp.Raw(args))); err != nil {
+		return nil, err
+	}
+
+	return NewTube(conn, name), nil
+}
+
+// NewTube returns existing tube.
+func NewTube(conn *tarantool.Connection, name string) *Tube {
+	return &Tube{conn: conn, name: name}
+}
+
+// Name returns name of tube.
+func (t *Tube) Name() string {
+	return t.name
+}
+
+// Drop drops tube with all its tasks.
+func (t *Tube) Drop(ctx context.Context) error {
+	_, err := t.call(ctx, "drop")
+
+	return err
+}
+
+// Put puts task with data.
+func (t *Tube) Put(ctx context.Context, data interface{}, opts PutOpts) (*Task, error) {
+	return t.task(t.call(ctx, "put", data, opts.values()))
+}
+
+// takeMargin is time left for response of take before deadline of context.
+const takeMargin = 100 * time.Millisecond
+
+// Take takes ready task, waiting for it up to timeout.
+// It returns nil task if there are no ready tasks.
+//
+// Task taken after the request is timed out on client stays taken until
+// its TTR, so timeout is limited by deadline of ctx. Opts.Timeout of
+// connection is not known here, it must be longer than timeout.
+func (t *Tube) Take(ctx context.Context, timeout time.Duration) (*Task, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := time.Until(deadline) - takeMargin; left < timeout {
+			timeout = left
+		}
+
+		if timeout < 0 {
+			timeout = 0
+		}
+	}
+
+	return t.task(t.call(ctx, "take", timeout.Seconds()))
+}
+
+// Ack marks taken task as done.
+func (t *Tube) Ack(ctx context.Context, id uint64) (*Task, error) {
+	return t.task(t.call(ctx, "ack", id))
+}
+
+// Release returns taken task to tube.
+func (t *Tube) Release(ctx context.Context, id uint64, opts ReleaseOpts) (*Task, error) {
+	values := make(map[string]interface{})
+
+	if opts.Delay > 0 {
+		values["delay"] = opts.Delay.Seconds()
+	}
+
+	return t.task(t.call(ctx, "release", id, values))
+}
+
+// Bury disables task until it is kicked.
+func (t *Tube) Bury(ctx context.Context, id uint64) (*Task, error) {
+	return t.task(t.call(ctx, "bury", id))
+}
+
+// Kick makes up to count buried tasks ready and returns their number.
+func (t *Tube) Kick(ctx context.Context, count uint64) (uint64, error) {
+	values, err := t.call(ctx, "kick", count)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	n, ok := toUint(values[0])
+	if !ok {
+		return 0, errors.Errorf("queue: kick returned %T", values[0])
+	}
+
+	return n, nil
+}
+
+// Peek returns task without changing its status.
+func (t *Tube) Peek(ctx context.Context, id uint64) (*Task, error) {
+	return t.task(t.call(ctx, "peek", id))
+}
+
+// Delete deletes task.
+func (t *Tube) Delete(ctx context.Context, id uint64) (*Task, error) {
+	return t.task(t.call(ctx, "delete", id))
+}
+
+// Statistics returns statistics of tube.
+func (t *Tube) Statistics(ctx context.Context) (*Statistics, error) {
+	args, err := msgp.AppendIntf(nil, []interface{}{t.name})
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := do(ctx, t.conn, tarantool.NewCall17Request("queue.statistics", msgp.Raw(args)))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 || values[0] == nil {
+		return nil, errors.Errorf("queue: no statistics of tube %s", t.name)
+	}
+
+	stats, ok := values[0].(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.Errorf("queue: statistics are %T", values[0])
+	}
+
+	res := &Statistics{Calls: make(map[string]uint64)}
+
+	if tasks, ok := stats["tasks"].(map[interface{}]interface{}); ok {
+		for key, value := range tasks {
+			n, _ := toUint(value)
+
+			switch key {
+			case "taken":
+				res.Tasks.Taken = n
+			case "buried":
+				res.Tasks.Buried = n
+			case "ready":
+				res.Tasks.Ready = n
+			case "done":
+				res.Tasks.Done = n
+			case "delayed":
+				res.Tasks.Delayed = n
+			case "total":
+				res.Tasks.Total = n
+			}
+		}
+	}
+
+	if calls, ok := stats["calls"].(map[interface{}]interface{}); ok {
+		for key, value := range calls {
+			if name, ok := key.(string); ok {
+				res.Calls[name], _ = toUint(value)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// call calls method of tube with args.
+func (t *Tube) call(ctx context.Context, method string, args ...interface{}) ([]interface{}, error) {
+	if args == nil {
+		args = []interface{}{}
+	}
+
+	b, err := msgp.AppendIntf(nil, args)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't encode arguments of %s", method)
+	}
+
+	return do(ctx, t.conn, tarantool.NewCall17Request(fmt.Sprintf("queue.tube.%s:%s", t.name, method), msgp.Raw(b)))
+}
+
+// task decodes task returned by call, nil is returned as nil task.
+func (t *Tube) task(values []interface{}, err error) (*Task, error) {
+	if err != nil || len(values) == 0 || values[0] == nil {
+		return nil, err
+	}
+
+	tuple, ok := values[0].([]interface{})
+	if !ok || len(tuple) < 2 {
+		return nil, errors.Errorf("queue: invalid task %v", values[0])
+	}
+
+	task := &Task{}
+
+	if task.ID, ok = toUint(tuple[0]); !ok {
+		return nil, errors.Errorf("queue: invalid task id %v", tuple[0])
+	}
+
+	status, ok := tuple[1].(string)
+	if !ok {
+		return nil, errors.Errorf("queue: invalid task status %v", tuple[1])
+	}
+
+	task.Status = Status(status)
+
+	if len(tuple) > 2 {
+		task.Data = tuple[2]
+	}
+
+	return task, nil
+}
+
+func do(ctx context.Context, conn *tarantool.Connection, req tarantool.Request) ([]interface{}, error) {
+	resp, err := conn.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Release()
+
+	if resp.Code != tarantool.OkCode {
+		return nil, tarantool.Error{Code: resp.Code, Msg: resp.Error}
+	}
+
+	return resp.Values()
+}
+
+func toUint(v interface{}) (uint64, bool) {
+	switch v := v.(type) {
+	case uint64:
+		return v, true
+	case int64:
+		return uint64(v), v >= 0
+	case float64:
+		// lua numbers could be encoded as doubles
+		return uint64(v), v >= 0 && v == float64(uint64(v))
+	default:
+		return 0, false
+	}
+}
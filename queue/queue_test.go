@@ -0,0 +1,344 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+	"gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/tarantooltest"
+)
+
+// fakeTube emulates tube of queue module on tarantooltest server.
+type fakeTube struct {
+	mutex sync.Mutex
+	typ   string
+	opts  map[interface{}]interface{}
+	tasks map[int64][]interface{}
+	next  int64
+	calls map[string]uint64
+	// puts are options of put calls
+	puts []map[interface{}]interface{}
+	// takes are timeouts of take calls
+	takes []float64
+}
+
+func (tube *fakeTube) register(s *tarantooltest.Server, name string) {
+	method := func(method string, f func(args []interface{}) []interface{}) {
+		s.RegisterFunc("queue.tube."+name+":"+method, func(args []interface{}) ([]interface{}, error) {
+			tube.mutex.Lock()
+			defer tube.mutex.Unlock()
+
+			tube.calls[method]++
+
+			return f(args), nil
+		})
+	}
+
+	// status sets status of task and returns it
+	status := func(id interface{}, status string) []interface{} {
+		task, ok := tube.tasks[id.(int64)]
+		if !ok {
+			return []interface{}{nil}
+		}
+
+		task[1] = status
+
+		return []interface{}{task}
+	}
+
+	method("put", func(args []interface{}) []interface{} {
+		opts := args[1].(map[interface{}]interface{})
+		tube.puts = append(tube.puts, opts)
+
+		task := []interface{}{tube.next, "r", args[0]}
+		if _, ok := opts["delay"]; ok {
+			task[1] = "~"
+		}
+
+		tube.tasks[tube.next] = task
+		tube.next++
+
+		return []interface{}{task}
+	})
+	method("take", func(args []interface{}) []interface{} {
+		timeout, ok := args[0].(float64)
+		if !ok {
+			return []interface{}{nil}
+		}
+
+		tube.takes = append(tube.takes, timeout)
+
+		ids := make([]int64, 0, len(tube.tasks))
+
+		for id := range tube.tasks {
+			ids = append(ids, id)
+		}
+
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		for _, id := range ids {
+			if tube.tasks[id][1] == "r" {
+				return status(id, "t")
+			}
+		}
+
+		return []interface{}{nil}
+	})
+	method("ack", func(args []interface{}) []interface{} {
+		defer delete(tube.tasks, args[0].(int64))
+
+		return status(args[0], "-")
+	})
+	method("release", func(args []interface{}) []interface{} {
+		if _, ok := args[1].(map[interface{}]interface{})["delay"]; ok {
+			return status(args[0], "~")
+		}
+
+		return status(args[0], "r")
+	})
+	method("bury", func(args []interface{}) []interface{} {
+		return status(args[0], "!")
+	})
+	method("kick", func(args []interface{}) []interface{} {
+		n := int64(0)
+
+		for id, task := range tube.tasks {
+			if n < args[0].(int64) && task[1] == "!" {
+				status(id, "r")
+				n++
+			}
+		}
+
+		return []interface{}{n}
+	})
+	method("peek", func(args []interface{}) []interface{} {
+		task, ok := tube.tasks[args[0].(int64)]
+		if !ok {
+			return []interface{}{nil}
+		}
+
+		return []interface{}{task}
+	})
+	method("delete", func(args []interface{}) []interface{} {
+		defer delete(tube.tasks, args[0].(int64))
+
+		return status(args[0], "-")
+	})
+	method("drop", func(args []interface{}) []interface{} {
+		tube.tasks = nil
+
+		return []interface{}{true}
+	})
+}
+
+func newServer(t *testing.T) (*tarantooltest.Server, map[string]*fakeTube) {
+	s, err := tarantooltest.NewServer("")
+	if err != nil {
+		t.Fatalf("Failed to start server: %s", err.Error())
+	}
+
+	t.Cleanup(func() { s.Close() })
+
+	var mutex sync.Mutex
+
+	tubes := make(map[string]*fakeTube)
+
+	s.RegisterEval(createExpr, func(args []interface{}) ([]interface{}, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		name := args[0].(string)
+		if _, ok := tubes[name]; ok {
+			return nil, errors.New("Tube already exists")
+		}
+
+		tube := &fakeTube{
+			typ:   args[1].(string),
+			opts:  args[2].(map[interface{}]interface{}),
+			tasks: make(map[int64][]interface{}),
+			calls: make(map[string]uint64),
+		}
+
+		tubes[name] = tube
+		tube.register(s, name)
+
+		return nil, nil
+	})
+
+	s.RegisterFunc("queue.statistics", func(args []interface{}) ([]interface{}, error) {
+		mutex.Lock()
+		tube := tubes[args[0].(string)]
+		mutex.Unlock()
+
+		tube.mutex.Lock()
+		defer tube.mutex.Unlock()
+
+		tasks := map[string]interface{}{"taken": 0, "buried": 0, "ready": 0, "done": 0, "delayed": 0}
+
+		for _, task := range tube.tasks {
+			switch task[1] {
+			case "t":
+				tasks["taken"] = tasks["taken"].(int) + 1
+			case "!":
+				tasks["buried"] = tasks["buried"].(int) + 1
+			case "r":
+				tasks["ready"] = tasks["ready"].(int) + 1
+			}
+		}
+
+		tasks["total"] = len(tube.tasks)
+
+		calls := make(map[string]interface{})
+
+		for name, n := range tube.calls {
+			calls[name] = n
+		}
+
+		return []interface{}{map[string]interface{}{"tasks": tasks, "calls": calls}}, nil
+	})
+
+	return s, tubes
+}
+
+func TestTube(t *testing.T) {
+	s, tubes := newServer(t)
+
+	conn, err := tarantool.Connect(s.Addr(), tarantool.Opts{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	tube, err := Create(ctx, conn, "mail", FIFOTTL, CreateOpts{Temporary: true, Capacity: 10})
+	if err != nil {
+		t.Fatalf("Failed to create tube: %s", err.Error())
+	}
+
+	if fake := tubes["mail"]; fake.typ != "fifottl" || fake.opts["temporary"] != true ||
+		fake.opts["if_not_exists"] != false || fake.opts["capacity"] != int64(10) {
+		t.Errorf("Unexpected tube %s with options %v", fake.typ, fake.opts)
+	}
+
+	if _, err = Create(ctx, conn, "mail", FIFO, CreateOpts{}); err == nil {
+		t.Errorf("Existing tube is created")
+	}
+
+	task, err := tube.Put(ctx, map[string]interface{}{"to": "user"}, PutOpts{TTL: time.Minute, TTR: 1500 * time.Millisecond, Pri: 2})
+	if expected := (&Task{0, Ready, map[interface{}]interface{}{"to": "user"}}); err != nil || !reflect.DeepEqual(task, expected) {
+		t.Errorf("Unexpected task %+v: %v", task, err)
+	}
+
+	task, err = tube.Put(ctx, "delayed", PutOpts{Delay: time.Second})
+	if err != nil || task.ID != 1 || task.Status != Delayed {
+		t.Errorf("Unexpected task %+v: %v", task, err)
+	}
+
+	if _, err = tube.Put(ctx, []interface{}{1, 2}, PutOpts{}); err != nil {
+		t.Errorf("Failed to put task: %s", err.Error())
+	}
+
+	puts := tubes["mail"].puts
+	if expected := []map[interface{}]interface{}{
+		{"ttl": 60.0, "ttr": 1.5, "pri": int64(2)},
+		{"delay": 1.0},
+		{},
+	}; !reflect.DeepEqual(puts, expected) {
+		t.Errorf("Unexpected options of put %v", puts)
+	}
+
+	task, err = tube.Take(ctx, time.Second)
+	if err != nil || task == nil || task.ID != 0 || task.Status != Taken {
+		t.Fatalf("Unexpected taken task %+v: %v", task, err)
+	}
+
+	if task, err = tube.Ack(ctx, task.ID); err != nil || task.Status != Done {
+		t.Errorf("Unexpected acked task %+v: %v", task, err)
+	}
+
+	task, err = tube.Take(ctx, 0)
+	if err != nil || task == nil || task.ID != 2 || !reflect.DeepEqual(task.Data, []interface{}{int64(1), int64(2)}) {
+		t.Fatalf("Unexpected taken task %+v: %v", task, err)
+	}
+
+	if task, err = tube.Take(ctx, 0); err != nil || task != nil {
+		t.Errorf("Unexpected task %+v of empty tube: %v", task, err)
+	}
+
+	if task, err = tube.Release(ctx, 2, ReleaseOpts{}); err != nil || task.Status != Ready {
+		t.Errorf("Unexpected released task %+v: %v", task, err)
+	}
+
+	if task, err = tube.Bury(ctx, 2); err != nil || task.Status != Buried {
+		t.Errorf("Unexpected buried task %+v: %v", task, err)
+	}
+
+	stats, err := tube.Statistics(ctx)
+	if err != nil || stats.Tasks != (TaskStatistics{Buried: 1, Total: 2}) || stats.Calls["put"] != 3 || stats.Calls["take"] != 3 {
+		t.Errorf("Unexpected statistics %+v: %v", stats, err)
+	}
+
+	if n, err := tube.Kick(ctx, 10); err != nil || n != 1 {
+		t.Errorf("Unexpected number of kicked tasks %d: %v", n, err)
+	}
+
+	if task, err = tube.Peek(ctx, 2); err != nil || task.Status != Ready {
+		t.Errorf("Unexpected peeked task %+v: %v", task, err)
+	}
+
+	if task, err = tube.Delete(ctx, 2); err != nil || task.ID != 2 {
+		t.Errorf("Unexpected deleted task %+v: %v", task, err)
+	}
+
+	if task, err = tube.Peek(ctx, 2); err != nil || task != nil {
+		t.Errorf("Unexpected peeked task %+v after delete: %v", task, err)
+	}
+
+	if err = tube.Drop(ctx); err != nil {
+		t.Errorf("Failed to drop tube: %s", err.Error())
+	}
+
+	_, err = NewTube(conn, "unknown").Put(ctx, "data", PutOpts{})
+	if tntErr := (tarantool.Error{}); !errors.As(err, &tntErr) || tntErr.Code != tarantool.ErrNoSuchProc {
+		t.Errorf("Unexpected error of unknown tube: %v", err)
+	}
+}
+
+func TestTakeTimeout(t *testing.T) {
+	s, tubes := newServer(t)
+
+	conn, err := tarantool.Connect(s.Addr(), tarantool.Opts{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+
+	defer conn.Close()
+
+	tube, err := Create(context.Background(), conn, "mail", FIFO, CreateOpts{})
+	if err != nil {
+		t.Fatalf("Failed to create tube: %s", err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	if _, err = tube.Take(ctx, time.Minute); err != nil {
+		t.Fatalf("Failed to take task: %s", err.Error())
+	}
+
+	if _, err = tube.Take(context.Background(), 2*time.Second); err != nil {
+		t.Fatalf("Failed to take task: %s", err.Error())
+	}
+
+	takes := tubes["mail"].takes
+	if len(takes) != 2 || takes[0] <= 0 || takes[0] > 0.4 || takes[1] != 2 {
+		t.Errorf("Unexpected timeouts of take %v", takes)
+	}
+}
@@ -0,0 +1,535 @@
+// Package replication streams changes of tarantool as anonymous replica,
+// ie to feed them into message brokers or to maintain external indexes.
+//
+// Replica fetches snapshot with Join and then subscribes to changes made
+// after vclock of snapshot, or after vclock saved by previous run:
+//
+//	r, err := replication.Connect("127.0.0.1:3301", replication.Opts{User: "replicator", Pass: "secret"})
+//	defer r.Close()
+//
+//	_, err = r.Subscribe(ctx, vclock)
+//
+//	for {
+//		row, err := r.Next()
+//		if err != nil {
+//			return err
+//		}
+//
+//		// row.Type, row.Space, row.Tuple...
+//		vclock = r.Vclock()
+//	}
+//
+// Master doesn't keep state of anonymous replicas: it sends rows which are
+// not applied by replica yet, if xlogs containing them are not collected.
+// User must have replication role.
+package replication
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+	"gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/server"
+)
+
+// IPROTO keys of replication requests.
+const (
+	keyServerVersion  = 0x06
+	keyInstanceUUID   = 0x24
+	keyReplicasetUUID = 0x25
+	keyVclock         = 0x26
+	keyReplicaAnon    = 0x50
+)
+
+// serverVersion is version of replica sent to master, 2.11.1.
+const serverVersion = 2<<16 | 11<<8 | 1
+
+// schemaSpace is id of _schema space, it stores replicaset uuid.
+const schemaSpace = 272
+
+// ErrClosed is returned by Next after Close.
+var ErrClosed = errors.New("replication: closed")
+
+// Opts are options of replica connection.
+type Opts struct {
+	// User and Pass are credentials, guest is used if User is empty.
+	User string
+	Pass string
+	// UUID is instance uuid of replica, random one is used if it is zero.
+	UUID tarantool.UUID
+	// ReplicasetUUID is uuid of replicaset of master, master rejects
+	// subscribe with another one. It is read from _schema space of master
+	// if it is zero.
+	ReplicasetUUID tarantool.UUID
+	// Timeout limits connect, join and subscribe requests, and waiting for
+	// every row. Master sends heartbeats every replication_timeout, so
+	// Timeout must be greater than it. Default is 30s.
+	Timeout time.Duration
+	// AckInterval is interval of acknowledgements of vclock sent to master
+	// after subscribe, master closes silent connections. Default is 1s.
+	AckInterval time.Duration
+	// MaxPacketSize is maximal size of row, it must be greater than
+	// memtx_max_tuple_size of master. Default is server.DefaultMaxPacketSize.
+	MaxPacketSize int
+}
+
+type state int
+
+const (
+	stateIdle state = iota
+	stateJoin
+	stateSubscribe
+)
+
+// Replica is connection to master as anonymous replica.
+// Its methods must not be called concurrently, but Close and Vclock.
+type Replica struct {
+	// Greeting is greeting of master.
+	Greeting *tarantool.Greeting
+
+	opts  Opts
+	c     net.Conn
+	r     *bufio.Reader
+	state state
+
+	// wmutex serializes writes of requests and acknowledgements
+	wmutex sync.Mutex
+	sync   uint64
+
+	mutex  sync.Mutex
+	vclock Vclock
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Connect connects to master and authenticates if opts.User is set.
+func Connect(addr string, opts Opts) (*Replica, error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+
+	if opts.AckInterval == 0 {
+		opts.AckInterval = time.Second
+	}
+
+	if opts.MaxPacketSize == 0 {
+		opts.MaxPacketSize = server.DefaultMaxPacketSize
+	}
+
+	if opts.UUID == (tarantool.UUID{}) {
+		uuid, err := tarantool.NewUUID()
+		if err != nil {
+			return nil, err
+		}
+
+		opts.UUID = uuid
+	}
+
+	c, err := net.DialTimeout("tcp", addr, opts.Timeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't connect")
+	}
+
+	r := &Replica{
+		opts:   opts,
+		c:      c,
+		r:      bufio.NewReader(c),
+		vclock: Vclock{},
+		closed: make(chan struct{}),
+	}
+
+	if err = r.handshake(); err != nil {
+		c.Close()
+
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *Replica) handshake() error {
+	r.c.SetDeadline(time.Now().Add(r.opts.Timeout))
+	defer r.c.SetDeadline(time.Time{})
+
+	greeting := make([]byte, 128)
+
+	if _, err := io.ReadFull(r.r, greeting); err != nil {
+		return errors.Wrap(err, "can't read greeting")
+	}
+
+	r.Greeting = &tarantool.Greeting{Version: string(greeting[:64])}
+
+	if fields := strings.Fields(r.Greeting.Version); len(fields) > 0 {
+		r.Greeting.UUID, _ = tarantool.ParseUUID(fields[len(fields)-1])
+	}
+
+	if r.opts.User == "" {
+		return nil
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(string(greeting[64:108]))
+	if err != nil || len(salt) < sha1.Size {
+		return errors.New("replication: invalid salt")
+	}
+
+	scramble := tarantool.Scramble(salt, r.opts.Pass)
+
+	_, err = r.request(tarantool.AuthRequest, func(b []byte) []byte {
+		b = msgp.AppendMapHeader(b, 2)
+		b = msgp.AppendUint64(b, tarantool.KeyUserName)
+		b = msgp.AppendString(b, r.opts.User)
+		b = msgp.AppendUint64(b, tarantool.KeyTuple)
+		b = msgp.AppendArrayHeader(b, 2)
+		b = msgp.AppendString(b, "chap-sha1")
+
+		return msgp.AppendStringFromBytes(b, scramble)
+	})
+
+	return err
+}
+
+// Join requests snapshot and returns its vclock. Rows of snapshot are
+// returned by Next, it returns io.EOF after the last one. Then Vclock
+// returns vclock of master after snapshot, it is used to subscribe.
+func (r *Replica) Join(ctx context.Context) (Vclock, error) {
+	if r.state != stateIdle {
+		return nil, errors.New("replication: join after join or subscribe")
+	}
+
+	var vclock Vclock
+
+	err := r.withContext(ctx, func() error {
+		resp, err := r.request(tarantool.FetchSnapshotRequest, func(b []byte) []byte {
+			b = msgp.AppendMapHeader(b, 1)
+			b = msgp.AppendUint64(b, keyServerVersion)
+
+			return msgp.AppendUint32(b, serverVersion)
+		})
+		if err != nil {
+			return err
+		}
+
+		vclock, err = decodeVclock(resp.Body[keyVclock])
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.state = stateJoin
+	r.setVclock(vclock)
+
+	return vclock.Clone(), nil
+}
+
+// Subscribe requests rows made after vclock and returns vclock of master.
+// Rows are returned by Next, and vclock of returned rows is acknowledged
+// to master every AckInterval.
+func (r *Replica) Subscribe(ctx context.Context, vclock Vclock) (Vclock, error) {
+	if r.state != stateIdle {
+		return nil, errors.New("replication: subscribe during join or after subscribe")
+	}
+
+	var master Vclock
+
+	err := r.withContext(ctx, func() error {
+		if r.opts.ReplicasetUUID == (tarantool.UUID{}) {
+			uuid, err := r.replicasetUUID()
+			if err != nil {
+				return err
+			}
+
+			r.opts.ReplicasetUUID = uuid
+		}
+
+		resp, err := r.request(tarantool.SubscribeRequest, func(b []byte) []byte {
+			b = msgp.AppendMapHeader(b, 5)
+			b = msgp.AppendUint64(b, keyInstanceUUID)
+			b = msgp.AppendString(b, r.opts.UUID.String())
+			b = msgp.AppendUint64(b, keyReplicasetUUID)
+			b = msgp.AppendString(b, r.opts.ReplicasetUUID.String())
+			b = msgp.AppendUint64(b, keyVclock)
+			b = appendVclock(b, vclock)
+			b = msgp.AppendUint64(b, keyServerVersion)
+			b = msgp.AppendUint32(b, serverVersion)
+			b = msgp.AppendUint64(b, keyReplicaAnon)
+
+			return msgp.AppendBool(b, true)
+		})
+		if err != nil {
+			return err
+		}
+
+		master, err = decodeVclock(resp.Body[keyVclock])
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.state = stateSubscribe
+	r.setVclock(vclock.Clone())
+
+	go r.acker()
+
+	return master, nil
+}
+
+// replicasetUUID reads replicaset uuid from _schema space of master.
+// It is stored with "cluster" key before 3.0 and "replicaset_uuid" since.
+func (r *Replica) replicasetUUID() (tarantool.UUID, error) {
+	for _, key := range []string{"cluster", "replicaset_uuid"} {
+		resp, err := r.request(tarantool.SelectRequest, func(b []byte) []byte {
+			b = msgp.AppendMapHeader(b, 5)
+			b = msgp.AppendUint64(b, tarantool.KeySpaceNo)
+			b = msgp.AppendUint64(b, schemaSpace)
+			b = msgp.AppendUint64(b, tarantool.KeyIndexNo)
+			b = msgp.AppendUint64(b, 0)
+			b = msgp.AppendUint64(b, tarantool.KeyLimit)
+			b = msgp.AppendUint64(b, 1)
+			b = msgp.AppendUint64(b, tarantool.KeyIterator)
+			b = msgp.AppendUint64(b, uint64(tarantool.IterEq))
+			b = msgp.AppendUint64(b, tarantool.KeyKey)
+			b = msgp.AppendArrayHeader(b, 1)
+
+			return msgp.AppendString(b, key)
+		})
+		if err != nil {
+			return tarantool.UUID{}, errors.Wrap(err, "can't read replicaset uuid")
+		}
+
+		data, _, err := tarantool.Decode(resp.Body[tarantool.KeyData])
+		if err != nil {
+			return tarantool.UUID{}, errors.Wrap(err, "can't decode replicaset uuid")
+		}
+
+		tuples, _ := data.([]interface{})
+		if len(tuples) == 0 {
+			continue
+		}
+
+		if tuple, ok := tuples[0].([]interface{}); ok && len(tuple) > 1 {
+			if s, ok := tuple[1].(string); ok {
+				return tarantool.ParseUUID(s)
+			}
+		}
+
+		return tarantool.UUID{}, errors.Errorf("replication: invalid replicaset uuid %v", tuples[0])
+	}
+
+	return tarantool.UUID{}, errors.New("replication: master has no replicaset uuid")
+}
+
+// Next returns next row of snapshot or changes. Heartbeats of master are skipped.
+// Vclock is advanced by row before return.
+func (r *Replica) Next() (*Row, error) {
+	for {
+		if r.state == stateIdle {
+			return nil, errors.New("replication: next without join or subscribe")
+		}
+
+		r.c.SetReadDeadline(time.Now().Add(r.opts.Timeout))
+
+		row, err := r.read()
+		if err != nil {
+			return nil, err
+		}
+
+		if row.Type != int32(tarantool.OkCode) {
+			if row.ReplicaID != 0 && row.LSN != 0 {
+				r.mutex.Lock()
+				r.vclock[row.ReplicaID] = row.LSN
+				r.mutex.Unlock()
+			}
+
+			return row, nil
+		}
+
+		if r.state == stateJoin {
+			// end of snapshot has vclock of master
+			vclock, err := decodeVclock(row.Body[keyVclock])
+			if err != nil {
+				return nil, err
+			}
+
+			r.state = stateIdle
+			r.setVclock(vclock)
+
+			return nil, io.EOF
+		}
+	}
+}
+
+// Vclock returns vclock of rows returned by Next.
+func (r *Replica) Vclock() Vclock {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.vclock.Clone()
+}
+
+func (r *Replica) setVclock(vclock Vclock) {
+	r.mutex.Lock()
+	r.vclock = vclock
+	r.mutex.Unlock()
+}
+
+// Close closes connection, Next returns ErrClosed then.
+func (r *Replica) Close() error {
+	var err error
+
+	r.closeOnce.Do(func() {
+		close(r.closed)
+		err = r.c.Close()
+	})
+
+	return err
+}
+
+func (r *Replica) acker() {
+	t := time.NewTicker(r.opts.AckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-r.closed:
+			return
+		case <-t.C:
+		}
+
+		vclock := r.Vclock()
+
+		// ack has no sync, master doesn't respond to it
+		b := appendPacket(nil, int32(tarantool.OkCode), 0, func(b []byte) []byte {
+			b = msgp.AppendMapHeader(b, 1)
+			b = msgp.AppendUint64(b, keyVclock)
+
+			return appendVclock(b, vclock)
+		})
+
+		if err := r.write(b); err != nil {
+			// Next fails as well
+			return
+		}
+	}
+}
+
+// withContext calls f with connection deadline of ctx or Timeout.
+func (r *Replica) withContext(ctx context.Context, f func() error) error {
+	deadline := time.Now().Add(r.opts.Timeout)
+
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	r.c.SetDeadline(deadline)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		select {
+		case <-ctx.Done():
+			// interrupt blocked read
+			r.c.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	err := f()
+
+	close(done)
+	<-stopped
+
+	r.c.SetDeadline(time.Time{})
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+// request sends request and reads its response.
+func (r *Replica) request(code int32, body func([]byte) []byte) (*Row, error) {
+	r.sync++
+
+	if err := r.write(appendPacket(nil, code, r.sync, body)); err != nil {
+		return nil, err
+	}
+
+	return r.read()
+}
+
+func (r *Replica) write(b []byte) error {
+	r.wmutex.Lock()
+	defer r.wmutex.Unlock()
+
+	if _, err := r.c.Write(b); err != nil {
+		return r.closedOr(errors.Wrap(err, "can't write"))
+	}
+
+	return nil
+}
+
+// read reads packet and returns error of error response.
+func (r *Replica) read() (*Row, error) {
+	packet, err := server.ReadPacket(r.r, r.opts.MaxPacketSize)
+	if err != nil {
+		return nil, r.closedOr(errors.Wrap(err, "can't read"))
+	}
+
+	row, err := DecodeRow(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	if code := uint32(row.Type); code&tarantool.ErrorCodeBit != 0 {
+		msg, _, _ := msgp.ReadStringBytes(row.Body[tarantool.KeyError])
+
+		return nil, tarantool.Error{Code: code &^ tarantool.ErrorCodeBit, Msg: msg}
+	}
+
+	return row, nil
+}
+
+func (r *Replica) closedOr(err error) error {
+	select {
+	case <-r.closed:
+		return ErrClosed
+	default:
+		return err
+	}
+}
+
+// appendPacket appends length, header and body appended by body func.
+func appendPacket(b []byte, code int32, sync uint64, body func([]byte) []byte) []byte {
+	start := len(b)
+
+	b = append(b, 0xce, 0, 0, 0, 0)
+	b = msgp.AppendMapHeader(b, 2)
+	b = msgp.AppendUint64(b, tarantool.KeyCode)
+	b = msgp.AppendUint32(b, uint32(code))
+	b = msgp.AppendUint64(b, tarantool.KeySync)
+	b = msgp.AppendUint64(b, sync)
+	b = body(b)
+
+	binary.BigEndian.PutUint32(b[start+1:], uint32(len(b)-start-5))
+
+	return b
+}
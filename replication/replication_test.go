@@ -0,0 +1,360 @@
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/GoWebProd/msgp/msgp"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+	"gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/server"
+)
+
+// master is scripted master, it reads requests of replica and writes packets.
+type master struct {
+	t *testing.T
+	c net.Conn
+	r *bufio.Reader
+	// body is encoded body of the last request
+	body []byte
+}
+
+// newMaster accepts the only connection and passes it to script.
+func newMaster(t *testing.T, script func(m *master)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		c, err := l.Accept()
+		if err != nil {
+			t.Errorf("Failed to accept: %s", err.Error())
+
+			return
+		}
+
+		defer c.Close()
+
+		c.SetDeadline(time.Now().Add(5 * time.Second))
+
+		greeting := bytes.Repeat([]byte{' '}, 128)
+		copy(greeting, "Tarantool 2.11.1 (Binary) 7f8c3b9e-6a54-4c4b-9d3e-2b1a0f9e8d7c")
+		greeting[63] = '\n'
+		base64.StdEncoding.Encode(greeting[64:], bytes.Repeat([]byte{1}, 32))
+		greeting[127] = '\n'
+
+		if _, err = c.Write(greeting); err != nil {
+			t.Errorf("Failed to write greeting: %s", err.Error())
+
+			return
+		}
+
+		script(&master{t: t, c: c, r: bufio.NewReader(c)})
+	}()
+
+	t.Cleanup(func() {
+		l.Close()
+		<-done
+	})
+
+	return l.Addr().String()
+}
+
+func (m *master) read() *server.Request {
+	var lenBuf [5]byte
+
+	if _, err := io.ReadFull(m.r, lenBuf[:]); err != nil {
+		m.t.Errorf("Failed to read request: %s", err.Error())
+
+		return &server.Request{Code: -1}
+	}
+
+	packet := make([]byte, binary.BigEndian.Uint32(lenBuf[1:]))
+
+	if _, err := io.ReadFull(m.r, packet); err != nil {
+		m.t.Errorf("Failed to read request: %s", err.Error())
+
+		return &server.Request{Code: -1}
+	}
+
+	req, err := server.DecodeRequest(packet)
+	if err != nil {
+		m.t.Errorf("Failed to decode request: %s", err.Error())
+
+		return &server.Request{Code: -1}
+	}
+
+	m.body, _ = msgp.Skip(packet)
+
+	return req
+}
+
+// write writes packet of header and body given as key-value pairs.
+func (m *master) write(header []interface{}, body ...interface{}) {
+	appendMap := func(b []byte, pairs []interface{}) []byte {
+		b = msgp.AppendMapHeader(b, uint32(len(pairs)/2))
+
+		for _, v := range pairs {
+			var err error
+
+			if b, err = msgp.AppendIntf(b, v); err != nil {
+				panic(err)
+			}
+		}
+
+		return b
+	}
+
+	b := appendMap([]byte{0xce, 0, 0, 0, 0}, header)
+	b = appendMap(b, body)
+	binary.BigEndian.PutUint32(b[1:], uint32(len(b)-5))
+
+	if _, err := m.c.Write(b); err != nil {
+		m.t.Errorf("Failed to write packet: %s", err.Error())
+	}
+}
+
+// vclockPair is body pair of vclock encoded as raw value.
+func vclockPair(v Vclock) []interface{} {
+	return []interface{}{keyVclock, msgp.Raw(appendVclock(nil, v))}
+}
+
+func TestReplica(t *testing.T) {
+	stamp := time.Date(2023, 1, 2, 3, 4, 5, 500000000, time.UTC)
+	ts := float64(stamp.UnixNano()) / 1e9
+
+	addr := newMaster(t, func(m *master) {
+		if req := m.read(); req.Code != tarantool.AuthRequest || req.UserName != "test" {
+			m.t.Errorf("Unexpected request %s of %s", req, req.UserName)
+		}
+
+		m.write([]interface{}{tarantool.KeyCode, 0, tarantool.KeySync, 1})
+
+		req := m.read()
+		if req.Code != tarantool.FetchSnapshotRequest {
+			m.t.Errorf("Unexpected request %s instead of join", req)
+		}
+
+		// server version 2.11.1
+		if body := fmt.Sprintf("%x", m.body); body != "81"+"06ce00020b01" {
+			m.t.Errorf("Unexpected body of join %s", body)
+		}
+
+		m.write([]interface{}{tarantool.KeyCode, 0, tarantool.KeySync, req.Sync}, vclockPair(Vclock{1: 10})...)
+		m.write([]interface{}{tarantool.KeyCode, tarantool.InsertRequest},
+			tarantool.KeySpaceNo, 512, tarantool.KeyTuple, []interface{}{1, "one"})
+		m.write([]interface{}{tarantool.KeyCode, tarantool.InsertRequest},
+			tarantool.KeySpaceNo, 512, tarantool.KeyTuple, []interface{}{2, "two"})
+		m.write([]interface{}{tarantool.KeyCode, 0, tarantool.KeySync, req.Sync}, vclockPair(Vclock{1: 12})...)
+
+		req = m.read()
+		if req.Code != tarantool.SelectRequest || req.Space != schemaSpace || fmt.Sprint(tarantool.Decode(req.Key)) != "[cluster] [] <nil>" {
+			m.t.Errorf("Unexpected request %s instead of select of replicaset uuid", req)
+		}
+
+		m.write([]interface{}{tarantool.KeyCode, 0, tarantool.KeySync, req.Sync},
+			tarantool.KeyData, []interface{}{[]interface{}{"cluster", "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"}})
+
+		req = m.read()
+		if req.Code != tarantool.SubscribeRequest {
+			m.t.Errorf("Unexpected request %s instead of subscribe", req)
+		}
+
+		expected := "85" +
+			"24d924" + fmt.Sprintf("%x", "11111111-2222-4333-8444-555555555555") +
+			"25d924" + fmt.Sprintf("%x", "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d") +
+			"2681010c" + // vclock {1: 12}
+			"06ce00020b01" + // server version 2.11.1
+			"50c3" // anonymous replica
+		if body := fmt.Sprintf("%x", m.body); body != expected {
+			m.t.Errorf("Unexpected body of subscribe:\n%s\nexpected:\n%s", body, expected)
+		}
+
+		m.write([]interface{}{tarantool.KeyCode, 0, tarantool.KeySync, req.Sync}, vclockPair(Vclock{1: 15})...)
+
+		// heartbeat
+		m.write([]interface{}{tarantool.KeyCode, 0, server.KeyReplicaID, 1, server.KeyTimestamp, ts})
+
+		m.write([]interface{}{tarantool.KeyCode, tarantool.ReplaceRequest, server.KeyReplicaID, 1,
+			server.KeyLSN, 13, server.KeyTimestamp, ts},
+			tarantool.KeySpaceNo, 512, tarantool.KeyTuple, []interface{}{3, "three"})
+
+		// transaction of two rows
+		m.write([]interface{}{tarantool.KeyCode, tarantool.UpdateRequest, server.KeyReplicaID, 1,
+			server.KeyLSN, 14, server.KeyTSN, 0, server.KeyTimestamp, ts},
+			tarantool.KeySpaceNo, 512, tarantool.KeyIndexNo, 0, tarantool.KeyKey, []interface{}{1},
+			tarantool.KeyTuple, []interface{}{[]interface{}{"=", 1, "uno"}})
+		m.write([]interface{}{tarantool.KeyCode, tarantool.DeleteRequest, server.KeyReplicaID, 1,
+			server.KeyLSN, 15, server.KeyTSN, 1, server.KeyFlags, server.FlagCommit, server.KeyTimestamp, ts},
+			tarantool.KeySpaceNo, 512, tarantool.KeyIndexNo, 0, tarantool.KeyKey, []interface{}{2})
+
+		for {
+			req := m.read()
+			if req.Code != int32(tarantool.OkCode) {
+				m.t.Errorf("Unexpected request %s instead of ack", req)
+
+				return
+			}
+
+			if vclock, _ := decodeVclock(req.Body[keyVclock]); vclock[1] == 15 {
+				return
+			}
+		}
+	})
+
+	r, err := Connect(addr, Opts{
+		User:        "test",
+		Pass:        "test",
+		UUID:        tarantool.UUID{0x11, 0x11, 0x11, 0x11, 0x22, 0x22, 0x43, 0x33, 0x84, 0x44, 0x55, 0x55, 0x55, 0x55, 0x55, 0x55},
+		Timeout:     time.Second,
+		AckInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+
+	defer r.Close()
+
+	if r.Greeting.UUID.String() != "7f8c3b9e-6a54-4c4b-9d3e-2b1a0f9e8d7c" {
+		t.Errorf("Unexpected greeting %+v", r.Greeting)
+	}
+
+	ctx := context.Background()
+
+	vclock, err := r.Join(ctx)
+	if err != nil || !reflect.DeepEqual(vclock, Vclock{1: 10}) {
+		t.Fatalf("Unexpected result of join %v: %v", vclock, err)
+	}
+
+	var snapshot []interface{}
+
+	for {
+		row, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Failed to read snapshot: %s", err.Error())
+		}
+
+		values, err := row.Values()
+		if err != nil || row.Type != tarantool.InsertRequest || row.Space != 512 || !row.Timestamp.IsZero() {
+			t.Errorf("Unexpected row %s at %s: %v", row, row.Timestamp, err)
+		}
+
+		snapshot = append(snapshot, values)
+	}
+
+	if expected := []interface{}{[]interface{}{int64(1), "one"}, []interface{}{int64(2), "two"}}; !reflect.DeepEqual(snapshot, expected) {
+		t.Errorf("Unexpected snapshot %v", snapshot)
+	}
+
+	if vclock = r.Vclock(); vclock.String() != "{1: 12}" {
+		t.Errorf("Unexpected vclock after join %s", vclock)
+	}
+
+	if vclock, err = r.Subscribe(ctx, vclock); err != nil || vclock.Sum() != 15 {
+		t.Fatalf("Unexpected result of subscribe %v: %v", vclock, err)
+	}
+
+	expected := []struct {
+		typ    int32
+		lsn    uint64
+		tsn    uint64
+		commit bool
+		values string
+	}{
+		{tarantool.ReplaceRequest, 13, 13, true, "[3 three]"},
+		{tarantool.UpdateRequest, 14, 14, false, "[1]"},
+		{tarantool.DeleteRequest, 15, 14, true, "[2]"},
+	}
+
+	for _, e := range expected {
+		row, err := r.Next()
+		if err != nil {
+			t.Fatalf("Failed to read row: %s", err.Error())
+		}
+
+		values, err := row.Values()
+		if err != nil || row.Type != e.typ || row.ReplicaID != 1 || row.LSN != e.lsn || row.TSN != e.tsn ||
+			row.Commit != e.commit || !row.Timestamp.Equal(stamp) || fmt.Sprint(values) != e.values {
+			t.Errorf("Unexpected row %s (tsn %d, commit %v) at %s: %v %v", row, row.TSN, row.Commit, row.Timestamp, values, err)
+		}
+
+		if row.Type == tarantool.UpdateRequest {
+			if ops, _, _ := tarantool.Decode(row.Ops); fmt.Sprint(ops) != "[[= 1 uno]]" {
+				t.Errorf("Unexpected ops of update %v", ops)
+			}
+		}
+	}
+
+	if vclock = r.Vclock(); !reflect.DeepEqual(vclock, Vclock{1: 15}) {
+		t.Errorf("Unexpected vclock after subscribe %v", vclock)
+	}
+
+	// master closes connection after ack of the last row
+	if _, err = r.Next(); err == nil {
+		t.Errorf("Next succeeded after master is gone")
+	}
+
+	r.Close()
+
+	if _, err = r.Next(); err != ErrClosed {
+		t.Errorf("Unexpected error of Next after close: %v", err)
+	}
+}
+
+func TestSubscribeError(t *testing.T) {
+	addr := newMaster(t, func(m *master) {
+		req := m.read()
+
+		m.write([]interface{}{tarantool.KeyCode, tarantool.ErrorCodeBit | tarantool.ErrAccessDenied, tarantool.KeySync, req.Sync},
+			tarantool.KeyError, "Read access to universe '' is denied for user 'guest'")
+	})
+
+	r, err := Connect(addr, Opts{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+
+	defer r.Close()
+
+	_, err = r.Subscribe(context.Background(), nil)
+	if tntErr := (tarantool.Error{}); !errors.As(err, &tntErr) || tntErr.Code != tarantool.ErrAccessDenied {
+		t.Errorf("Unexpected error of subscribe: %v", err)
+	}
+}
+
+func TestPacketTooLarge(t *testing.T) {
+	addr := newMaster(t, func(m *master) {
+		m.read()
+
+		if _, err := m.c.Write([]byte{0xce, 0x7f, 0xff, 0xff, 0xff}); err != nil {
+			m.t.Errorf("Failed to write packet: %s", err.Error())
+		}
+	})
+
+	r, err := Connect(addr, Opts{Timeout: time.Second, MaxPacketSize: 1024})
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+
+	defer r.Close()
+
+	if _, err = r.Join(context.Background()); !errors.Is(err, server.ErrPacketTooLarge) {
+		t.Errorf("Unexpected error of large packet: %v", err)
+	}
+}
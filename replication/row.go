@@ -0,0 +1,193 @@
+package replication
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+	"gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/server"
+)
+
+// Vclock maps replica id to LSN of its last row.
+type Vclock map[uint32]uint64
+
+// Clone returns copy of vclock.
+func (v Vclock) Clone() Vclock {
+	c := make(Vclock, len(v))
+
+	for id, lsn := range v {
+		c[id] = lsn
+	}
+
+	return c
+}
+
+// Sum returns sum of LSNs, it is signature of vclock used in xlog file names.
+func (v Vclock) Sum() uint64 {
+	sum := uint64(0)
+
+	for _, lsn := range v {
+		sum += lsn
+	}
+
+	return sum
+}
+
+// String formats vclock as tarantool does, ie "{1: 10, 2: 5}".
+func (v Vclock) String() string {
+	ids := make([]int, 0, len(v))
+
+	for id := range v {
+		ids = append(ids, int(id))
+	}
+
+	sort.Ints(ids)
+
+	parts := make([]string, len(ids))
+
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("%d: %d", id, v[uint32(id)])
+	}
+
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func appendVclock(b []byte, v Vclock) []byte {
+	b = msgp.AppendMapHeader(b, uint32(len(v)))
+
+	for id, lsn := range v {
+		b = msgp.AppendUint32(b, id)
+		b = msgp.AppendUint64(b, lsn)
+	}
+
+	return b
+}
+
+func decodeVclock(b []byte) (Vclock, error) {
+	n, b, err := msgp.ReadMapHeaderBytes(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decode vclock")
+	}
+
+	v := make(Vclock, n)
+
+	for ; n > 0; n-- {
+		var (
+			id  uint32
+			lsn uint64
+		)
+
+		if id, b, err = msgp.ReadUint32Bytes(b); err != nil {
+			return nil, errors.Wrap(err, "can't decode vclock")
+		}
+
+		if lsn, b, err = msgp.ReadUint64Bytes(b); err != nil {
+			return nil, errors.Wrap(err, "can't decode vclock")
+		}
+
+		v[id] = lsn
+	}
+
+	return v, nil
+}
+
+// Row is decoded row of xlog. Usually it is data change request:
+// insert, replace, update, delete or upsert. Other types are passed
+// as is, ie nop, or raft and synchronous replication rows of newer versions.
+type Row struct {
+	// Type is request code, ie tarantool.InsertRequest.
+	Type      int32
+	ReplicaID uint32
+	LSN       uint64
+	// Timestamp is time of row creation, it is zero for snapshot rows.
+	Timestamp time.Time
+	// TSN is transaction id, ie LSN of its first row.
+	TSN uint64
+	// Commit is set on the last row of transaction.
+	Commit bool
+
+	Space uint32
+	Index uint32
+	// Key is key of update and delete.
+	Key msgp.Raw
+	// Tuple is tuple of insert, replace and upsert.
+	Tuple msgp.Raw
+	// Ops are operations of update and upsert.
+	Ops msgp.Raw
+	// IndexBase is base of field numbers of update operations.
+	IndexBase uint32
+
+	// Body contains all keys of row body.
+	Body map[uint64]msgp.Raw
+}
+
+// DecodeRow decodes row packet without length, ie header and body.
+// Raw values of row refer to packet.
+func DecodeRow(packet []byte) (*Row, error) {
+	req, err := server.DecodeRequest(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	row := &Row{
+		Type:      req.Code,
+		ReplicaID: req.ReplicaID,
+		LSN:       req.LSN,
+		TSN:       req.TSN,
+		Commit:    req.Flags&server.FlagCommit != 0,
+		Space:     req.Space,
+		Index:     req.Index,
+		Key:       req.Key,
+		Tuple:     req.Tuple,
+		Ops:       req.Ops,
+		IndexBase: req.IndexBase,
+		Body:      req.Body,
+	}
+
+	if req.Timestamp != 0 {
+		sec, frac := math.Modf(req.Timestamp)
+		row.Timestamp = time.Unix(int64(sec), int64(frac*1e9))
+	}
+
+	return row, nil
+}
+
+// Values decodes tuple, or key of update and delete, with tarantool.Decode.
+func (row *Row) Values() ([]interface{}, error) {
+	raw := row.Tuple
+	if raw == nil {
+		raw = row.Key
+	}
+
+	if raw == nil {
+		return nil, nil
+	}
+
+	v, _, err := tarantool.Decode(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("tuple is %T, not array", v)
+	}
+
+	return values, nil
+}
+
+// String returns row type, space and position for logging.
+func (row *Row) String() string {
+	switch row.Type {
+	case tarantool.InsertRequest, tarantool.ReplaceRequest, tarantool.UpdateRequest,
+		tarantool.DeleteRequest, tarantool.UpsertRequest:
+		return fmt.Sprintf("%s space %d lsn %d:%d", tarantool.RequestName(row.Type), row.Space, row.ReplicaID, row.LSN)
+	default:
+		return fmt.Sprintf("%s lsn %d:%d", tarantool.RequestName(row.Type), row.ReplicaID, row.LSN)
+	}
+}
@@ -1,6 +1,8 @@
 package tarantool
 
 import (
+	"context"
+
 	"github.com/GoWebProd/msgp/msgp"
 	"github.com/pkg/errors"
 )
@@ -18,11 +20,44 @@ type request struct {
 	tuple    Body
 	function string
 
+	after      []byte
+	afterTuple Body
+	fetchPos   bool
+
 	userName string
 	method   string
 	scramble []byte
 }
 
+var requestNames = map[int32]string{
+	SelectRequest:        "select",
+	InsertRequest:        "insert",
+	ReplaceRequest:       "replace",
+	UpdateRequest:        "update",
+	DeleteRequest:        "delete",
+	CallRequest:          "call",
+	AuthRequest:          "auth",
+	EvalRequest:          "eval",
+	UpsertRequest:        "upsert",
+	Call17Request:        "call17",
+	NopRequest:           "nop",
+	PingRequest:          "ping",
+	JoinRequest:          "join",
+	SubscribeRequest:     "subscribe",
+	FetchSnapshotRequest: "fetch_snapshot",
+	WatchRequest:         "watch",
+	EventRequest:         "event",
+}
+
+// RequestName returns lowercase name of request code, ie "select".
+func RequestName(code int32) string {
+	if name, ok := requestNames[code]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
 func (z *request) EncodeMsg(en *msgp.Writer) error {
 	switch z.requestCode {
 	case AuthRequest:
@@ -37,8 +72,13 @@ func (z *request) EncodeMsg(en *msgp.Writer) error {
 		return nil
 	case PingRequest:
 		return en.WriteMapHeader(0)
+	case WatchRequest:
+		en.WriteMapHeader(1)
+		en.WriteUint64(KeyEventKey)
+
+		return en.WriteString(z.function)
 	case SelectRequest:
-		en.WriteMapHeader(6)
+		en.WriteMapHeader(z.selectKeys())
 		en.WriteUint64(KeyIterator)
 		en.WriteUint32(z.iterator)
 		en.WriteUint64(KeyOffset)
@@ -49,6 +89,21 @@ func (z *request) EncodeMsg(en *msgp.Writer) error {
 		en.WriteUint32(z.space)
 		en.WriteUint64(KeyIndexNo)
 		en.WriteUint32(z.index)
+
+		if z.fetchPos {
+			en.WriteUint64(KeyFetchPos)
+			en.WriteBool(true)
+		}
+
+		switch {
+		case z.after != nil:
+			en.WriteUint64(KeyAfterPos)
+			en.WriteStringFromBytes(z.after)
+		case z.afterTuple != nil:
+			en.WriteUint64(KeyAfterTuple)
+			z.afterTuple.EncodeMsg(en)
+		}
+
 		en.WriteUint64(KeyKey)
 
 		if z.key == nil {
@@ -147,15 +202,43 @@ func (z *request) EncodeMsg(en *msgp.Writer) error {
 	return errors.Errorf("bad request: %d", z.requestCode)
 }
 
+// selectKeys returns number of keys in select request body.
+func (z *request) selectKeys() uint32 {
+	n := uint32(6)
+
+	if z.fetchPos {
+		n++
+	}
+
+	if z.after != nil || z.afterTuple != nil {
+		n++
+	}
+
+	return n
+}
+
 func (z *request) Msgsize() int {
 	switch z.requestCode {
 	case AuthRequest:
 		return 2 + msgp.IntSize(KeyUserName) + msgp.StringSize(len(z.userName)) + msgp.IntSize(KeyTuple) + msgp.StringSize(len(z.method)) + msgp.StringSize(len(z.scramble))
 	case PingRequest:
 		return 1
+	case WatchRequest:
+		return 2 + msgp.StringSize(len(z.function))
 	case SelectRequest:
 		s := 7 + msgp.IntSize(uint64(z.iterator)) + msgp.IntSize(uint64(z.offset)) + msgp.IntSize(uint64(z.limit)) + msgp.IntSize(uint64(z.space)) + msgp.IntSize(uint64(z.index))
 
+		if z.fetchPos {
+			s += 2
+		}
+
+		switch {
+		case z.after != nil:
+			s += 1 + msgp.StringSize(len(z.after))
+		case z.afterTuple != nil:
+			s += 1 + z.afterTuple.Msgsize()
+		}
+
 		if z.key == nil {
 			s += 1
 		} else {
@@ -230,20 +313,28 @@ func (z *request) Msgsize() int {
 	return 0
 }
 
-// Ping sends empty request to Tarantool to check connection.
-func (conn *Connection) Ping() (resp Response, err error) {
-	future := conn.newFuture(request{
+// NewPingRequest creates request for Do.
+func NewPingRequest() Request {
+	return Request{r: request{
 		requestCode: PingRequest,
-	})
+	}}
+}
 
-	conn.queue <- future
+// PingAsync sends empty request to Tarantool and returns Future.
+func (conn *Connection) PingAsync() *Future {
+	return conn.DoAsync(context.Background(), NewPingRequest())
+}
 
-	return future.Get()
+// Ping sends empty request to Tarantool to check connection.
+//
+// It is equal to conn.Do(context.Background(), NewPingRequest()).
+func (conn *Connection) Ping() (resp Response, err error) {
+	return conn.Do(context.Background(), NewPingRequest())
 }
 
-// SelectAsync sends select request to tarantool and returns Future.
-func (conn *Connection) SelectAsync(space, index, offset, limit, iterator uint32, key Body) *Future {
-	future := conn.newFuture(request{
+// NewSelectRequest creates select request for Do.
+func NewSelectRequest(space, index, offset, limit, iterator uint32, key Body) Request {
+	return Request{r: request{
 		requestCode: SelectRequest,
 
 		space:    space,
@@ -252,204 +343,216 @@ func (conn *Connection) SelectAsync(space, index, offset, limit, iterator uint32
 		limit:    limit,
 		iterator: iterator,
 		key:      key,
-	})
-
-	conn.queue <- future
+	}}
+}
 
-	return future
+// SelectAsync sends select request to tarantool and returns Future.
+func (conn *Connection) SelectAsync(space, index, offset, limit, iterator uint32, key Body) *Future {
+	return conn.DoAsync(context.Background(), NewSelectRequest(space, index, offset, limit, iterator, key))
 }
 
 // Select performs select to box space.
 //
-// It is equal to conn.SelectAsync(...).Get()
+// It is equal to conn.Do(context.Background(), NewSelectRequest(...)).
 func (conn *Connection) Select(space, index, offset, limit, iterator uint32, key Body) (resp Response, err error) {
-	return conn.SelectAsync(space, index, offset, limit, iterator, key).Get()
+	return conn.Do(context.Background(), NewSelectRequest(space, index, offset, limit, iterator, key))
 }
 
-// InsertAsync sends insert action to tarantool and returns Future.
-// Tarantool will reject Insert when tuple with same primary key exists.
-func (conn *Connection) InsertAsync(space uint32, tuple Body) *Future {
-	future := conn.newFuture(request{
+// NewInsertRequest creates insert request for Do.
+func NewInsertRequest(space uint32, tuple Body) Request {
+	return Request{r: request{
 		requestCode: InsertRequest,
 
 		space: space,
 		tuple: tuple,
-	})
-
-	conn.queue <- future
+	}}
+}
 
-	return future
+// InsertAsync sends insert action to tarantool and returns Future.
+// Tarantool will reject Insert when tuple with same primary key exists.
+func (conn *Connection) InsertAsync(space uint32, tuple Body) *Future {
+	return conn.DoAsync(context.Background(), NewInsertRequest(space, tuple))
 }
 
 // Insert performs insertion to box space.
 // Tarantool will reject Insert when tuple with same primary key exists.
 //
-// It is equal to conn.InsertAsync(space, tuple).Get().
+// It is equal to conn.Do(context.Background(), NewInsertRequest(space, tuple)).
 func (conn *Connection) Insert(space uint32, tuple Body) (resp Response, err error) {
-	return conn.InsertAsync(space, tuple).Get()
+	return conn.Do(context.Background(), NewInsertRequest(space, tuple))
 }
 
-// ReplaceAsync sends "insert or replace" action to tarantool and returns Future.
-// If tuple with same primary key exists, it will be replaced.
-func (conn *Connection) ReplaceAsync(space uint32, tuple Body) *Future {
-	future := conn.newFuture(request{
+// NewReplaceRequest creates "insert or replace" request for Do.
+func NewReplaceRequest(space uint32, tuple Body) Request {
+	return Request{r: request{
 		requestCode: ReplaceRequest,
 
 		space: space,
 		tuple: tuple,
-	})
-
-	conn.queue <- future
+	}}
+}
 
-	return future
+// ReplaceAsync sends "insert or replace" action to tarantool and returns Future.
+// If tuple with same primary key exists, it will be replaced.
+func (conn *Connection) ReplaceAsync(space uint32, tuple Body) *Future {
+	return conn.DoAsync(context.Background(), NewReplaceRequest(space, tuple))
 }
 
 // Replace performs "insert or replace" action to box space.
 // If tuple with same primary key exists, it will be replaced.
 //
-// It is equal to conn.ReplaceAsync(space, tuple).Get().
+// It is equal to conn.Do(context.Background(), NewReplaceRequest(space, tuple)).
 func (conn *Connection) Replace(space uint32, tuple Body) (resp Response, err error) {
-	return conn.ReplaceAsync(space, tuple).Get()
+	return conn.Do(context.Background(), NewReplaceRequest(space, tuple))
 }
 
-// DeleteAsync sends deletion action to tarantool and returns Future.
-// Future's result will contain array with deleted tuple.
-func (conn *Connection) DeleteAsync(space, index uint32, key Body) *Future {
-	future := conn.newFuture(request{
+// NewDeleteRequest creates deletion request for Do.
+func NewDeleteRequest(space, index uint32, key Body) Request {
+	return Request{r: request{
 		requestCode: DeleteRequest,
 
 		space: space,
 		index: index,
 		key:   key,
-	})
-
-	conn.queue <- future
+	}}
+}
 
-	return future
+// DeleteAsync sends deletion action to tarantool and returns Future.
+// Future's result will contain array with deleted tuple.
+func (conn *Connection) DeleteAsync(space, index uint32, key Body) *Future {
+	return conn.DoAsync(context.Background(), NewDeleteRequest(space, index, key))
 }
 
 // Delete performs deletion of a tuple by key.
 // Result will contain array with deleted tuple.
 //
-// It is equal to conn.DeleteAsync(space, tuple).Get().
+// It is equal to conn.Do(context.Background(), NewDeleteRequest(space, index, key)).
 func (conn *Connection) Delete(space, index uint32, key Body) (resp Response, err error) {
-	return conn.DeleteAsync(space, index, key).Get()
+	return conn.Do(context.Background(), NewDeleteRequest(space, index, key))
 }
 
-// Update sends deletion of a tuple by key and returns Future.
-// Future's result will contain array with updated tuple.
-func (conn *Connection) UpdateAsync(space, index uint32, key, ops Body) *Future {
-	future := conn.newFuture(request{
+// NewUpdateRequest creates update request for Do.
+func NewUpdateRequest(space, index uint32, key, ops Body) Request {
+	return Request{r: request{
 		requestCode: UpdateRequest,
 
 		space: space,
 		index: index,
 		key:   key,
 		tuple: ops,
-	})
-
-	conn.queue <- future
+	}}
+}
 
-	return future
+// Update sends deletion of a tuple by key and returns Future.
+// Future's result will contain array with updated tuple.
+func (conn *Connection) UpdateAsync(space, index uint32, key, ops Body) *Future {
+	return conn.DoAsync(context.Background(), NewUpdateRequest(space, index, key, ops))
 }
 
 // Update performs update of a tuple by key.
 // Result will contain array with updated tuple.
 //
-// It is equal to conn.UpdateAsync(space, tuple).Get().
+// It is equal to conn.Do(context.Background(), NewUpdateRequest(space, index, key, ops)).
 func (conn *Connection) Update(space, index uint32, key, ops Body) (resp Response, err error) {
-	return conn.UpdateAsync(space, index, key, ops).Get()
+	return conn.Do(context.Background(), NewUpdateRequest(space, index, key, ops))
 }
 
-// UpsertAsync sends "update or insert" action to tarantool and returns Future.
-// Future's sesult will not contain any tuple.
-func (conn *Connection) UpsertAsync(space uint32, key, ops Body) *Future {
-	future := conn.newFuture(request{
+// NewUpsertRequest creates "update or insert" request for Do.
+func NewUpsertRequest(space uint32, tuple, ops Body) Request {
+	return Request{r: request{
 		requestCode: UpsertRequest,
 
 		space: space,
-		key:   key,
+		key:   tuple,
 		tuple: ops,
-	})
-
-	conn.queue <- future
+	}}
+}
 
-	return future
+// UpsertAsync sends "update or insert" action to tarantool and returns Future.
+// Future's sesult will not contain any tuple.
+func (conn *Connection) UpsertAsync(space uint32, key, ops Body) *Future {
+	return conn.DoAsync(context.Background(), NewUpsertRequest(space, key, ops))
 }
 
 // Upsert performs "update or insert" action of a tuple by key.
 // Result will not contain any tuple.
 //
-// It is equal to conn.UpsertAsync(space, tuple, ops).Get().
+// It is equal to conn.Do(context.Background(), NewUpsertRequest(space, tuple, ops)).
 func (conn *Connection) Upsert(space uint32, tuple, ops Body) (resp Response, err error) {
-	return conn.UpsertAsync(space, tuple, ops).Get()
+	return conn.Do(context.Background(), NewUpsertRequest(space, tuple, ops))
 }
 
-// CallAsync sends a call to registered tarantool function and returns Future.
-// It uses request code for tarantool 1.6, so future's result is always array of arrays
-func (conn *Connection) CallAsync(functionName string, args Body) *Future {
-	future := conn.newFuture(request{
+// NewCallRequest creates request for Do which calls registered tarantool function.
+// It uses request code for tarantool 1.6, so result is always array of arrays
+func NewCallRequest(functionName string, args Body) Request {
+	return Request{r: request{
 		requestCode: CallRequest,
 
 		function: functionName,
 		tuple:    args,
-	})
-
-	conn.queue <- future
+	}}
+}
 
-	return future
+// CallAsync sends a call to registered tarantool function and returns Future.
+// It uses request code for tarantool 1.6, so future's result is always array of arrays
+func (conn *Connection) CallAsync(functionName string, args Body) *Future {
+	return conn.DoAsync(context.Background(), NewCallRequest(functionName, args))
 }
 
 // Call calls registered tarantool function.
 // It uses request code for tarantool 1.6, so result is converted to array of arrays
 //
-// It is equal to conn.CallAsync(functionName, args).Get().
+// It is equal to conn.Do(context.Background(), NewCallRequest(functionName, args)).
 func (conn *Connection) Call(functionName string, args Body) (resp Response, err error) {
-	return conn.CallAsync(functionName, args).Get()
+	return conn.Do(context.Background(), NewCallRequest(functionName, args))
 }
 
-// Call17Async sends a call to registered tarantool function and returns Future.
-// It uses request code for tarantool 1.7, so future's result will not be converted
+// NewCall17Request creates request for Do which calls registered tarantool function.
+// It uses request code for tarantool 1.7, so result will not be converted
 // (though, keep in mind, result is always array)
-func (conn *Connection) Call17Async(functionName string, args Body) *Future {
-	future := conn.newFuture(request{
+func NewCall17Request(functionName string, args Body) Request {
+	return Request{r: request{
 		requestCode: Call17Request,
 
 		function: functionName,
 		tuple:    args,
-	})
-
-	conn.queue <- future
+	}}
+}
 
-	return future
+// Call17Async sends a call to registered tarantool function and returns Future.
+// It uses request code for tarantool 1.7, so future's result will not be converted
+// (though, keep in mind, result is always array)
+func (conn *Connection) Call17Async(functionName string, args Body) *Future {
+	return conn.DoAsync(context.Background(), NewCall17Request(functionName, args))
 }
 
 // Call17 calls registered tarantool function.
 // It uses request code for tarantool 1.7, so result is not converted
 // (though, keep in mind, result is always array)
 //
-// It is equal to conn.Call17Async(functionName, args).Get().
+// It is equal to conn.Do(context.Background(), NewCall17Request(functionName, args)).
 func (conn *Connection) Call17(functionName string, args Body) (resp Response, err error) {
-	return conn.Call17Async(functionName, args).Get()
+	return conn.Do(context.Background(), NewCall17Request(functionName, args))
 }
 
-// EvalAsync sends a lua expression for evaluation and returns Future.
-func (conn *Connection) EvalAsync(expr string, args Body) *Future {
-	future := conn.newFuture(request{
+// NewEvalRequest creates request for Do which evaluates lua expression.
+func NewEvalRequest(expr string, args Body) Request {
+	return Request{r: request{
 		requestCode: EvalRequest,
 
 		function: expr,
 		tuple:    args,
-	})
-
-	conn.queue <- future
+	}}
+}
 
-	return future
+// EvalAsync sends a lua expression for evaluation and returns Future.
+func (conn *Connection) EvalAsync(expr string, args Body) *Future {
+	return conn.DoAsync(context.Background(), NewEvalRequest(expr, args))
 }
 
 // Eval passes lua expression for evaluation.
 //
-// It is equal to conn.EvalAsync(expr, tuple).Get().
+// It is equal to conn.Do(context.Background(), NewEvalRequest(expr, args)).
 func (conn *Connection) Eval(expr string, args Body) (resp Response, err error) {
-	return conn.EvalAsync(expr, args).Get()
+	return conn.Do(context.Background(), NewEvalRequest(expr, args))
 }
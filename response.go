@@ -10,14 +10,24 @@ type Response struct {
 	Code      uint32
 	Error     string // error message
 	Data      []byte
+	// Position is a position of the last selected tuple, it is returned
+	// by select with SelectOpts.FetchPos. It refers to response buffer.
+	Position []byte
 
-	buf []byte
+	buf   []byte
+	event string
 }
 
+// Release returns response buffer to allocator.
+// Data must not be used after Release.
 func (resp *Response) Release() {
-	if resp.buf != nil {
+	if resp.buf != nil && debugFreeBuffer(resp.buf) {
 		allocator.Free(resp.buf)
 	}
+
+	resp.buf = nil
+	resp.Data = nil
+	resp.Position = nil
 }
 
 func (resp *Response) decode() error {
@@ -81,6 +91,18 @@ func (resp *Response) decode() error {
 			if resp.Error, remain, err = msgp.ReadStringBytes(remain); err != nil {
 				return err
 			}
+		case KeyPosition:
+			if resp.Position, remain, err = msgp.ReadStringZC(remain); err != nil {
+				return err
+			}
+		case KeyEventKey:
+			if resp.event, remain, err = msgp.ReadStringBytes(remain); err != nil {
+				return err
+			}
+		case KeyEventData:
+			if resp.Data, remain, err = getRawBody(remain); err != nil {
+				return err
+			}
 		default:
 			if remain, err = msgp.Skip(remain); err != nil {
 				return err
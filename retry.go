@@ -0,0 +1,110 @@
+package tarantool
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultRetryBackoff    = 10 * time.Millisecond
+	defaultRetryMaxBackoff = time.Second
+)
+
+// RetryPolicy configures retrying of idempotent requests,
+// see Request.IsIdempotent. Requests are retried on temporary
+// client errors and on ErrReadonly and ErrNonmaster server errors.
+type RetryPolicy struct {
+	// MaxAttempts is a maximum number of attempts including the first one.
+	// Zero or one disables retries.
+	MaxAttempts int
+	// Backoff is a pause before the first retry, it is doubled for every
+	// next one. Pause is randomized by up to a half. By default it is 10ms.
+	Backoff time.Duration
+	// MaxBackoff limits pause between attempts. By default it is 1s.
+	MaxBackoff time.Duration
+}
+
+type retryKey struct{}
+
+// WithRetry returns context which overrides Opts.Retry for requests
+// performed with it by Do and DoAsync. Zero policy disables retries.
+func WithRetry(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryKey{}, policy)
+}
+
+func (conn *Connection) retryPolicy(ctx context.Context) RetryPolicy {
+	if policy, ok := ctx.Value(retryKey{}).(RetryPolicy); ok {
+		return policy
+	}
+
+	return conn.opts.Retry
+}
+
+func (conn *Connection) retries(ctx context.Context, req Request) bool {
+	return req.IsIdempotent() && conn.retryPolicy(ctx).MaxAttempts > 1
+}
+
+// retry wraps invoker with retries of idempotent requests.
+func (conn *Connection) retry(next Invoker) Invoker {
+	return func(ctx context.Context, req Request) (Response, error) {
+		if !conn.retries(ctx, req) {
+			return next(ctx, req)
+		}
+
+		policy := conn.retryPolicy(ctx)
+
+		backoff := policy.Backoff
+		if backoff <= 0 {
+			backoff = defaultRetryBackoff
+		}
+
+		maxBackoff := policy.MaxBackoff
+		if maxBackoff <= 0 {
+			maxBackoff = defaultRetryMaxBackoff
+		}
+
+		for attempt := 1; ; attempt++ {
+			resp, err := next(ctx, req)
+			if attempt >= policy.MaxAttempts || !retryable(resp, err) {
+				return resp, err
+			}
+
+			conn.opts.Logger.Debug("tarantool: retrying request", "addr", conn.addr,
+				"request_id", resp.RequestId, "attempt", attempt, "code", resp.Code, "error", err)
+
+			pause := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+
+			t := time.NewTimer(pause)
+
+			select {
+			case <-ctx.Done():
+				t.Stop()
+
+				return resp, err
+			case <-t.C:
+			}
+
+			resp.Release()
+
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}
+}
+
+func retryable(resp Response, err error) bool {
+	if err != nil {
+		clierr, ok := err.(ClientError)
+
+		return ok && clierr.Temporary()
+	}
+
+	switch resp.Code {
+	case ErrReadonly, ErrNonmaster:
+		return true
+	default:
+		return false
+	}
+}
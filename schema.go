@@ -46,6 +46,12 @@ const (
 	vindexSpId = 289
 )
 
+// selectSchema selects system space bypassing interceptors,
+// as it is performed before lazy connection is ready.
+func (conn *Connection) selectSchema(space uint32) (Response, error) {
+	return conn.newFuture(NewSelectRequest(space, 0, 0, maxSchemas, IterAll, nil).r).Get()
+}
+
 func (conn *Connection) loadSchema() (err error) {
 	schema := new(Schema)
 	schema.SpacesById = make(map[uint32]*Space)
@@ -54,7 +60,7 @@ func (conn *Connection) loadSchema() (err error) {
 	var response SpaceResponses
 
 	// reload spaces
-	resp, err := conn.Select(vspaceSpId, 0, 0, maxSchemas, IterAll, nil)
+	resp, err := conn.selectSchema(vspaceSpId)
 	if err != nil {
 		resp.Release()
 
@@ -96,7 +102,7 @@ func (conn *Connection) loadSchema() (err error) {
 	var indexes IndexResponses
 
 	// reload indexes
-	resp, err = conn.Select(vindexSpId, 0, 0, maxSchemas, IterAll, nil)
+	resp, err = conn.selectSchema(vindexSpId)
 	if err != nil {
 		resp.Release()
 
@@ -123,11 +129,19 @@ func (conn *Connection) loadSchema() (err error) {
 		schema.SpacesById[row.SpaceId].Indexes[index.Name] = index
 	}
 
-	conn.Schema = schema
+	conn.schema.Store(schema)
 
 	return nil
 }
 
+// GetSchema returns schema loaded on connection, or nil if it is not loaded.
+// It is safe to call it concurrently with schema loading of lazy connection.
+func (conn *Connection) GetSchema() *Schema {
+	schema, _ := conn.schema.Load().(*Schema)
+
+	return schema
+}
+
 func (schema *Schema) ResolveSpaceIndex(s string, i string) (uint32, uint32, error) {
 	var (
 		spaceNo uint32
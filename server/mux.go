@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+// Handler handles decoded request. Returned error is sent to client,
+// its code is taken from tarantool.Error, or ErrProcLua is used.
+// Request and its raw values must not be used after return.
+type Handler interface {
+	ServeIPROTO(ctx context.Context, req *Request) (Response, error)
+}
+
+// HandlerFunc is function which implements Handler.
+type HandlerFunc func(ctx context.Context, req *Request) (Response, error)
+
+// ServeIPROTO calls f(ctx, req).
+func (f HandlerFunc) ServeIPROTO(ctx context.Context, req *Request) (Response, error) {
+	return f(ctx, req)
+}
+
+// Func is Go function called by call, call17 and eval requests.
+// Arguments are decoded with tarantool.Decode.
+type Func func(ctx context.Context, args []interface{}) ([]interface{}, error)
+
+// Mux routes call, call17 and eval requests to functions, and other
+// requests to handlers by request code.
+type Mux struct {
+	mutex    sync.RWMutex
+	funcs    map[string]Func
+	evals    map[string]Func
+	handlers map[int32]Handler
+}
+
+// NewMux creates empty Mux.
+func NewMux() *Mux {
+	return &Mux{
+		funcs:    make(map[string]Func),
+		evals:    make(map[string]Func),
+		handlers: make(map[int32]Handler),
+	}
+}
+
+// HandleFunc registers function called by call and call17 requests.
+// Results of call request of 1.6 are returned as tuples:
+// values which are not arrays are wrapped into ones.
+func (m *Mux) HandleFunc(name string, f Func) {
+	m.mutex.Lock()
+	m.funcs[name] = f
+	m.mutex.Unlock()
+}
+
+// HandleEval registers function called by eval request of expr.
+// Expressions are not parsed, so expr must be equal to requested one.
+func (m *Mux) HandleEval(expr string, f Func) {
+	m.mutex.Lock()
+	m.evals[expr] = f
+	m.mutex.Unlock()
+}
+
+// Handle registers handler of requests with code, ie tarantool.SelectRequest.
+// Handler of call, call17 or eval is used for functions which are not registered.
+func (m *Mux) Handle(code int32, h Handler) {
+	m.mutex.Lock()
+	m.handlers[code] = h
+	m.mutex.Unlock()
+}
+
+// ServeIPROTO implements Handler.
+func (m *Mux) ServeIPROTO(ctx context.Context, req *Request) (Response, error) {
+	m.mutex.RLock()
+
+	var (
+		f  Func
+		ok bool
+	)
+
+	switch req.Code {
+	case tarantool.CallRequest, tarantool.Call17Request:
+		f, ok = m.funcs[req.FunctionName]
+	case tarantool.EvalRequest:
+		f, ok = m.evals[req.FunctionName]
+	}
+
+	h := m.handlers[req.Code]
+
+	m.mutex.RUnlock()
+
+	switch {
+	case ok:
+		return call(ctx, req, f)
+	case h != nil:
+		return h.ServeIPROTO(ctx, req)
+	}
+
+	switch req.Code {
+	case tarantool.CallRequest, tarantool.Call17Request:
+		return Response{}, Error(tarantool.ErrNoSuchProc, "Procedure '%s' is not defined", req.FunctionName)
+	case tarantool.EvalRequest:
+		return Response{}, Error(tarantool.ErrProcLua, "Eval of '%s' is not supported", req.FunctionName)
+	default:
+		return Response{}, Error(tarantool.ErrUnknownRequestType, "Unknown request type %d", req.Code)
+	}
+}
+
+func call(ctx context.Context, req *Request, f Func) (Response, error) {
+	args, err := req.Args()
+	if err != nil {
+		return Response{}, Error(tarantool.ErrProtocol, "Invalid arguments: %s", err)
+	}
+
+	results, err := f(ctx, args)
+	if err != nil {
+		return Response{}, err
+	}
+
+	if req.Code == tarantool.CallRequest {
+		for i, res := range results {
+			if _, ok := res.([]interface{}); !ok {
+				results[i] = []interface{}{res}
+			}
+		}
+	}
+
+	return Values(results...)
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+// IPROTO keys which are not used by client.
+const (
+	KeyReplicaID     = 0x02
+	KeyLSN           = 0x03
+	KeyTimestamp     = 0x04
+	KeySchemaVersion = 0x05
+	KeyTSN           = 0x08
+	KeyFlags         = 0x09
+	KeyIndexBase     = 0x15
+	KeyOps           = 0x28
+)
+
+// FlagCommit is set in Flags of the last row of transaction.
+const FlagCommit = 0x01
+
+// Request is decoded IPROTO request. Raw values refer to packet buffer.
+type Request struct {
+	Code int32
+	Sync uint64
+
+	// SchemaVersion, ReplicaID, LSN, Timestamp, TSN and Flags are set
+	// by replication and in xlog rows.
+	SchemaVersion uint64
+	ReplicaID     uint32
+	LSN           uint64
+	Timestamp     float64
+	// TSN is transaction id, ie LSN of its first row. If row has no TSN,
+	// it is transaction of single row: TSN is LSN and FlagCommit is set.
+	TSN   uint64
+	Flags uint64
+
+	Space uint32
+	Index uint32
+	// Offset is zero and Limit is math.MaxUint32 if they are not set.
+	Offset   uint32
+	Limit    uint32
+	Iterator uint32
+	// IndexBase is base of field numbers of update operations.
+	IndexBase uint32
+
+	// Key is key of select, update and delete.
+	Key msgp.Raw
+	// Tuple is tuple of insert, replace and upsert, or arguments of call and eval.
+	Tuple msgp.Raw
+	// Ops are operations of update and upsert.
+	Ops msgp.Raw
+
+	// FunctionName is name of called function or evaluated expression.
+	FunctionName string
+	// UserName is user of auth request.
+	UserName string
+
+	FetchPos   bool
+	After      []byte
+	AfterTuple msgp.Raw
+
+	// Body contains all keys of request body.
+	Body map[uint64]msgp.Raw
+
+	// User is user authenticated on connection, "guest" by default.
+	User string
+	// RemoteAddr is address of client.
+	RemoteAddr net.Addr
+}
+
+// DecodeRequest decodes packet without length, ie header and body.
+func DecodeRequest(packet []byte) (*Request, error) {
+	req := &Request{
+		Limit:    math.MaxUint32,
+		Iterator: tarantool.IterEq,
+		Body:     make(map[uint64]msgp.Raw),
+	}
+
+	n, remain, err := msgp.ReadMapHeaderBytes(packet)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decode header")
+	}
+
+	hasTSN := false
+
+	for ; n > 0; n-- {
+		var key uint64
+
+		if key, remain, err = msgp.ReadUint64Bytes(remain); err != nil {
+			return nil, errors.Wrap(err, "can't decode header")
+		}
+
+		switch key {
+		case tarantool.KeyCode:
+			var code uint32
+
+			code, remain, err = msgp.ReadUint32Bytes(remain)
+			req.Code = int32(code)
+		case tarantool.KeySync:
+			req.Sync, remain, err = msgp.ReadUint64Bytes(remain)
+		case KeySchemaVersion:
+			req.SchemaVersion, remain, err = msgp.ReadUint64Bytes(remain)
+		case KeyReplicaID:
+			req.ReplicaID, remain, err = msgp.ReadUint32Bytes(remain)
+		case KeyLSN:
+			req.LSN, remain, err = msgp.ReadUint64Bytes(remain)
+		case KeyTimestamp:
+			req.Timestamp, remain, err = msgp.ReadFloat64Bytes(remain)
+		case KeyTSN:
+			// TSN is sent as difference with LSN
+			req.TSN, remain, err = msgp.ReadUint64Bytes(remain)
+			hasTSN = true
+		case KeyFlags:
+			req.Flags, remain, err = msgp.ReadUint64Bytes(remain)
+		default:
+			remain, err = msgp.Skip(remain)
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't decode header key 0x%x", key)
+		}
+	}
+
+	if hasTSN {
+		req.TSN = req.LSN - req.TSN
+	} else if req.LSN != 0 {
+		req.TSN = req.LSN
+		req.Flags |= FlagCommit
+	}
+
+	if len(remain) == 0 {
+		// ping has no body
+		return req, nil
+	}
+
+	if n, remain, err = msgp.ReadMapHeaderBytes(remain); err != nil {
+		return nil, errors.Wrap(err, "can't decode body")
+	}
+
+	for ; n > 0; n-- {
+		var key uint64
+
+		if key, remain, err = msgp.ReadUint64Bytes(remain); err != nil {
+			return nil, errors.Wrap(err, "can't decode body")
+		}
+
+		value := remain
+
+		if remain, err = msgp.Skip(remain); err != nil {
+			return nil, errors.Wrapf(err, "can't decode body key 0x%x", key)
+		}
+
+		value = value[:len(value)-len(remain)]
+		req.Body[key] = value
+
+		if err = req.decodeKey(key, value); err != nil {
+			return nil, errors.Wrapf(err, "can't decode body key 0x%x", key)
+		}
+	}
+
+	return req, nil
+}
+
+func (req *Request) decodeKey(key uint64, value msgp.Raw) (err error) {
+	switch key {
+	case tarantool.KeySpaceNo:
+		req.Space, _, err = msgp.ReadUint32Bytes(value)
+	case tarantool.KeyIndexNo:
+		req.Index, _, err = msgp.ReadUint32Bytes(value)
+	case tarantool.KeyOffset:
+		req.Offset, _, err = msgp.ReadUint32Bytes(value)
+	case tarantool.KeyLimit:
+		req.Limit, _, err = msgp.ReadUint32Bytes(value)
+	case tarantool.KeyIterator:
+		req.Iterator, _, err = msgp.ReadUint32Bytes(value)
+	case KeyIndexBase:
+		req.IndexBase, _, err = msgp.ReadUint32Bytes(value)
+	case tarantool.KeyKey:
+		req.Key = value
+	case tarantool.KeyTuple:
+		// update has operations in tuple
+		if req.Code == tarantool.UpdateRequest {
+			req.Ops = value
+		} else {
+			req.Tuple = value
+		}
+	case KeyOps:
+		req.Ops = value
+	case tarantool.KeyFunctionName, tarantool.KeyExpression:
+		req.FunctionName, _, err = msgp.ReadStringBytes(value)
+	case tarantool.KeyUserName:
+		req.UserName, _, err = msgp.ReadStringBytes(value)
+	case tarantool.KeyFetchPos:
+		req.FetchPos, _, err = msgp.ReadBoolBytes(value)
+	case tarantool.KeyAfterPos:
+		req.After, _, err = msgp.ReadStringZC(value)
+	case tarantool.KeyAfterTuple:
+		req.AfterTuple = value
+	}
+
+	return err
+}
+
+// Args decodes arguments of call and eval.
+func (req *Request) Args() ([]interface{}, error) {
+	if len(req.Tuple) == 0 {
+		return nil, nil
+	}
+
+	v, _, err := tarantool.Decode(req.Tuple)
+	if err != nil {
+		return nil, err
+	}
+
+	args, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("arguments are %T, not array", v)
+	}
+
+	return args, nil
+}
+
+// String returns request name and its main parameters for logging.
+func (req *Request) String() string {
+	name := tarantool.RequestName(req.Code)
+
+	switch req.Code {
+	case tarantool.CallRequest, tarantool.Call17Request, tarantool.EvalRequest:
+		return fmt.Sprintf("%s %s", name, req.FunctionName)
+	case tarantool.SelectRequest, tarantool.UpdateRequest, tarantool.DeleteRequest:
+		return fmt.Sprintf("%s space %d index %d", name, req.Space, req.Index)
+	case tarantool.InsertRequest, tarantool.ReplaceRequest, tarantool.UpsertRequest:
+		return fmt.Sprintf("%s space %d", name, req.Space)
+	default:
+		return name
+	}
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+// Response is body of successful response.
+type Response struct {
+	// Data is encoded array returned in response, ie tuples or results of call.
+	// If it is nil, response has no data, as ping does.
+	Data msgp.Raw
+	// Position is position of the last selected tuple returned by select with FetchPos.
+	Position []byte
+	// SchemaVersion is sent in header if it is not zero.
+	SchemaVersion uint64
+}
+
+// Values returns response with values encoded as data array.
+// Values decoded by tarantool.Decode could be returned as is.
+func Values(values ...interface{}) (Response, error) {
+	if values == nil {
+		values = []interface{}{}
+	}
+
+	b, err := appendValue(nil, values)
+	if err != nil {
+		return Response{}, errors.Wrap(err, "can't encode values")
+	}
+
+	return Response{Data: b}, nil
+}
+
+// appendValue appends value as msgp.AppendIntf does, but it supports
+// maps with keys of any type, ie map[interface{}]interface{}.
+func appendValue(b []byte, v interface{}) ([]byte, error) {
+	var err error
+
+	switch v := v.(type) {
+	case []interface{}:
+		b = msgp.AppendArrayHeader(b, uint32(len(v)))
+
+		for _, item := range v {
+			if b, err = appendValue(b, item); err != nil {
+				return b, err
+			}
+		}
+
+		return b, nil
+	case map[interface{}]interface{}:
+		b = msgp.AppendMapHeader(b, uint32(len(v)))
+
+		for key, value := range v {
+			if b, err = appendValue(b, key); err != nil {
+				return b, err
+			}
+
+			if b, err = appendValue(b, value); err != nil {
+				return b, err
+			}
+		}
+
+		return b, nil
+	case map[string]interface{}:
+		b = msgp.AppendMapHeader(b, uint32(len(v)))
+
+		for key, value := range v {
+			b = msgp.AppendString(b, key)
+
+			if b, err = appendValue(b, value); err != nil {
+				return b, err
+			}
+		}
+
+		return b, nil
+	default:
+		return msgp.AppendIntf(b, v)
+	}
+}
+
+// Error returns tarantool error with code and message, which is sent to
+// client as is. Other errors are sent with ErrProcLua code.
+func Error(code uint32, format string, args ...interface{}) error {
+	return tarantool.Error{Code: code, Msg: fmt.Sprintf(format, args...)}
+}
+
+func appendResponse(b []byte, sync uint64, resp Response) []byte {
+	return appendPacket(b, tarantool.OkCode, sync, resp.SchemaVersion, func(b []byte) []byte {
+		keys := uint32(0)
+
+		if resp.Data != nil {
+			keys++
+		}
+
+		if resp.Position != nil {
+			keys++
+		}
+
+		b = msgp.AppendMapHeader(b, keys)
+
+		if resp.Data != nil {
+			b = msgp.AppendUint64(b, tarantool.KeyData)
+			b = append(b, resp.Data...)
+		}
+
+		if resp.Position != nil {
+			b = msgp.AppendUint64(b, tarantool.KeyPosition)
+			b = msgp.AppendStringFromBytes(b, resp.Position)
+		}
+
+		return b
+	})
+}
+
+func appendError(b []byte, sync uint64, err error) []byte {
+	code, msg := uint32(tarantool.ErrProcLua), err.Error()
+
+	var tntErr tarantool.Error
+	if errors.As(err, &tntErr) {
+		code, msg = tntErr.Code, tntErr.Msg
+	}
+
+	return appendPacket(b, tarantool.ErrorCodeBit|code, sync, 0, func(b []byte) []byte {
+		b = msgp.AppendMapHeader(b, 1)
+		b = msgp.AppendUint64(b, tarantool.KeyError)
+
+		return msgp.AppendString(b, msg)
+	})
+}
+
+// appendPacket appends length, header and body appended by body func.
+func appendPacket(b []byte, code uint32, sync, schemaVersion uint64, body func([]byte) []byte) []byte {
+	start := len(b)
+	keys := uint32(2)
+
+	if schemaVersion != 0 {
+		keys++
+	}
+
+	b = append(b, 0xce, 0, 0, 0, 0)
+	b = msgp.AppendMapHeader(b, keys)
+	b = msgp.AppendUint64(b, tarantool.KeyCode)
+	b = msgp.AppendUint32(b, code)
+	b = msgp.AppendUint64(b, tarantool.KeySync)
+	b = msgp.AppendUint64(b, sync)
+
+	if schemaVersion != 0 {
+		b = msgp.AppendUint64(b, KeySchemaVersion)
+		b = msgp.AppendUint64(b, schemaVersion)
+	}
+
+	b = body(b)
+
+	binary.BigEndian.PutUint32(b[start+1:], uint32(len(b)-start-5))
+
+	return b
+}
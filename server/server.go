@@ -0,0 +1,517 @@
+// Package server implements IPROTO server, so Go services could be called
+// by tarantool connectors natively, as if they were tarantool instances.
+//
+// Server does greeting, chap-sha1 auth and ping itself, other requests are
+// decoded and passed to Handler. Mux routes call, call17 and eval requests
+// to Go functions by name, and other requests to handlers by request code:
+//
+//	mux := server.NewMux()
+//	mux.HandleFunc("echo", func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+//		return args, nil
+//	})
+//
+//	srv := &server.Server{Handler: mux}
+//	err := srv.ListenAndServe(":3301")
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+// DefaultVersion is tarantool version sent in greeting by default.
+const DefaultVersion = "Tarantool 2.11.1 (Binary)"
+
+// ErrServerClosed is returned by Serve after Close.
+var ErrServerClosed = errors.New("server: closed")
+
+// Server accepts IPROTO connections. Its fields must not be changed after Serve.
+type Server struct {
+	// Handler handles requests but ping and auth.
+	Handler Handler
+	// Auth returns password of user for chap-sha1 authentication.
+	// If it is nil, only guest could be used.
+	Auth func(user string) (password string, ok bool)
+	// Version is sent in greeting, DefaultVersion is used if it is empty.
+	Version string
+	// UUID is instance uuid sent in greeting, random one is used if it is zero.
+	UUID tarantool.UUID
+	// MaxInFlight is maximal number of requests of connection handled
+	// concurrently. If it is zero, requests are handled one by one
+	// in order of arrival.
+	MaxInFlight int
+	// MaxPacketSize is maximal size of request, connection is closed
+	// on larger one. DefaultMaxPacketSize is used if it is zero.
+	MaxPacketSize int
+	// Logger receives connection errors.
+	Logger tarantool.Logger
+
+	mutex     sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*conn]struct{}
+	closed    bool
+	serving   sync.WaitGroup
+}
+
+// ListenAndServe listens TCP address and serves connections until Close.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "can't listen")
+	}
+
+	return s.Serve(l)
+}
+
+// Serve accepts connections of listener until Close, listener is closed on return.
+func (s *Server) Serve(l net.Listener) error {
+	if err := s.init(l); err != nil {
+		l.Close()
+
+		return err
+	}
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.listeners, l)
+		s.mutex.Unlock()
+
+		l.Close()
+		s.serving.Done()
+	}()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			s.mutex.Lock()
+			closed := s.closed
+			s.mutex.Unlock()
+
+			if closed {
+				return ErrServerClosed
+			}
+
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+
+			return err
+		}
+
+		cn := s.newConn(c)
+
+		s.mutex.Lock()
+
+		if s.closed {
+			s.mutex.Unlock()
+			c.Close()
+
+			return ErrServerClosed
+		}
+
+		s.conns[cn] = struct{}{}
+		s.serving.Add(1)
+
+		s.mutex.Unlock()
+
+		go cn.serve()
+	}
+}
+
+func (s *Server) init(l net.Listener) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return ErrServerClosed
+	}
+
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+		s.conns = make(map[*conn]struct{})
+
+		if s.Version == "" {
+			s.Version = DefaultVersion
+		}
+
+		if s.UUID == (tarantool.UUID{}) {
+			uuid, err := tarantool.NewUUID()
+			if err != nil {
+				return err
+			}
+
+			s.UUID = uuid
+		}
+
+		if s.MaxPacketSize == 0 {
+			s.MaxPacketSize = DefaultMaxPacketSize
+		}
+
+		if s.Logger == nil {
+			s.Logger = nopLogger{}
+		}
+	}
+
+	s.listeners[l] = struct{}{}
+	s.serving.Add(1)
+
+	return nil
+}
+
+// Close closes listeners and connections, and waits for handlers to return.
+// Context of requests is canceled.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+
+	if s.closed {
+		s.mutex.Unlock()
+
+		return nil
+	}
+
+	s.closed = true
+
+	var err error
+
+	for l := range s.listeners {
+		if lerr := l.Close(); lerr != nil && err == nil {
+			err = lerr
+		}
+	}
+
+	for c := range s.conns {
+		c.close()
+	}
+
+	s.mutex.Unlock()
+
+	s.serving.Wait()
+
+	return err
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
+
+// conn is client connection.
+type conn struct {
+	server *Server
+	c      net.Conn
+	salt   []byte
+	user   string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// out is queue of encoded responses
+	out      chan []byte
+	inFlight chan struct{}
+	handlers sync.WaitGroup
+}
+
+func (s *Server) newConn(c net.Conn) *conn {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cn := &conn{
+		server: s,
+		c:      c,
+		user:   "guest",
+		ctx:    ctx,
+		cancel: cancel,
+		out:    make(chan []byte, 128),
+	}
+
+	if s.MaxInFlight > 0 {
+		cn.inFlight = make(chan struct{}, s.MaxInFlight)
+	}
+
+	return cn
+}
+
+func (cn *conn) close() {
+	cn.cancel()
+	cn.c.Close()
+}
+
+func (cn *conn) serve() {
+	s := cn.server
+
+	defer func() {
+		cn.close()
+
+		// handlers could send responses until they return
+		cn.handlers.Wait()
+		close(cn.out)
+
+		s.mutex.Lock()
+		delete(s.conns, cn)
+		s.mutex.Unlock()
+
+		s.serving.Done()
+	}()
+
+	cn.salt = make([]byte, 32)
+
+	if _, err := rand.Read(cn.salt); err != nil {
+		s.Logger.Error("tarantool: can't generate salt", "addr", cn.c.RemoteAddr(), "err", err)
+
+		return
+	}
+
+	if _, err := cn.c.Write(greeting(s.Version, s.UUID, cn.salt)); err != nil {
+		return
+	}
+
+	go cn.writer(bufio.NewWriter(cn.c))
+
+	r := bufio.NewReader(cn.c)
+
+	for {
+		packet, err := ReadPacket(r, s.MaxPacketSize)
+		if err != nil {
+			if err != io.EOF && cn.ctx.Err() == nil {
+				s.Logger.Warn("tarantool: read failed", "addr", cn.c.RemoteAddr(), "err", err)
+			}
+
+			return
+		}
+
+		req, err := DecodeRequest(packet)
+		if err != nil {
+			// tarantool closes connection on malformed packet as well
+			s.Logger.Warn("tarantool: malformed request", "addr", cn.c.RemoteAddr(), "err", err)
+
+			return
+		}
+
+		req.User = cn.user
+		req.RemoteAddr = cn.c.RemoteAddr()
+
+		switch req.Code {
+		case tarantool.PingRequest:
+			cn.respond(req, Response{}, nil)
+		case tarantool.AuthRequest:
+			cn.respond(req, Response{}, cn.auth(req))
+		case tarantool.WatchRequest:
+			// events are not supported, so there is nothing to send
+		default:
+			cn.handle(req)
+		}
+	}
+}
+
+// handle passes request to handler, concurrently if MaxInFlight is set.
+func (cn *conn) handle(req *Request) {
+	if cn.inFlight == nil {
+		resp, err := cn.server.Handler.ServeIPROTO(cn.ctx, req)
+		cn.respond(req, resp, err)
+
+		return
+	}
+
+	select {
+	case cn.inFlight <- struct{}{}:
+	case <-cn.ctx.Done():
+		return
+	}
+
+	cn.handlers.Add(1)
+
+	go func() {
+		defer func() {
+			<-cn.inFlight
+			cn.handlers.Done()
+		}()
+
+		resp, err := cn.server.Handler.ServeIPROTO(cn.ctx, req)
+		cn.respond(req, resp, err)
+	}()
+}
+
+func (cn *conn) respond(req *Request, resp Response, err error) {
+	var b []byte
+
+	if err != nil {
+		b = appendError(b, req.Sync, err)
+	} else {
+		b = appendResponse(b, req.Sync, resp)
+	}
+
+	select {
+	case cn.out <- b:
+	case <-cn.ctx.Done():
+	}
+}
+
+func (cn *conn) writer(w *bufio.Writer) {
+	for b := range cn.out {
+		if _, err := w.Write(b); err != nil {
+			cn.close()
+
+			continue
+		}
+
+		// responses are pipelined, so flush only when there are no more of them
+		if len(cn.out) == 0 {
+			if err := w.Flush(); err != nil {
+				cn.close()
+			}
+		}
+	}
+}
+
+func (cn *conn) auth(req *Request) error {
+	var (
+		method   string
+		scramble []byte
+	)
+
+	_, tuple, err := msgp.ReadArrayHeaderBytes(req.Tuple)
+	if err == nil {
+		method, tuple, err = msgp.ReadStringBytes(tuple)
+	}
+
+	if err == nil {
+		scramble, _, err = msgp.ReadStringZC(tuple)
+	}
+
+	if req.UserName == "guest" {
+		cn.user = req.UserName
+
+		return nil
+	}
+
+	if err != nil {
+		return tarantool.Error{Code: tarantool.ErrProtocol, Msg: "Invalid auth request"}
+	}
+
+	var (
+		password string
+		ok       bool
+	)
+
+	if cn.server.Auth != nil {
+		password, ok = cn.server.Auth(req.UserName)
+	}
+
+	if !ok {
+		return tarantool.Error{
+			Code: tarantool.ErrNoSuchUser,
+			Msg:  fmt.Sprintf("User '%s' is not found", req.UserName),
+		}
+	}
+
+	if method != "chap-sha1" {
+		return tarantool.Error{
+			Code: tarantool.ErrUnsupported,
+			Msg:  fmt.Sprintf("Authentication method '%s' is not supported", method),
+		}
+	}
+
+	if !bytes.Equal(scramble, tarantool.Scramble(cn.salt, password)) {
+		return tarantool.Error{
+			Code: tarantool.ErrPasswordMismatch,
+			Msg:  fmt.Sprintf("Incorrect password supplied for user '%s'", req.UserName),
+		}
+	}
+
+	cn.user = req.UserName
+
+	return nil
+}
+
+// greeting returns greeting of 128 bytes: version with instance uuid
+// and base64 encoded salt, both padded with spaces to 64 bytes.
+func greeting(version string, uuid tarantool.UUID, salt []byte) []byte {
+	b := bytes.Repeat([]byte{' '}, 128)
+
+	copy(b[:63], fmt.Sprintf("%s %s", version, uuid))
+	b[63] = '\n'
+
+	base64.StdEncoding.Encode(b[64:], salt)
+	b[127] = '\n'
+
+	return b
+}
+
+// DefaultMaxPacketSize is maximal size of request by default.
+const DefaultMaxPacketSize = 16 << 20
+
+// ErrPacketTooLarge is returned by ReadPacket if packet exceeds limit.
+var ErrPacketTooLarge = errors.New("server: packet is too large")
+
+// ReadPacket reads packet of IPROTO stream without length. Length is
+// msgpack unsigned integer of any size. Packets larger than maxSize are
+// not read, ErrPacketTooLarge is returned.
+func ReadPacket(r *bufio.Reader, maxSize int) ([]byte, error) {
+	code, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	var size int
+
+	switch {
+	case code <= 0x7f:
+		// positive fixint
+	case code == 0xcc:
+		size = 1
+	case code == 0xcd:
+		size = 2
+	case code == 0xce:
+		size = 4
+	case code == 0xcf:
+		size = 8
+	default:
+		return nil, errors.Errorf("server: invalid packet length type 0x%02x", code)
+	}
+
+	lenBuf := make([]byte, 1+size)
+	lenBuf[0] = code
+
+	if _, err = io.ReadFull(r, lenBuf[1:]); err != nil {
+		return nil, noEOF(err)
+	}
+
+	length, _, err := msgp.ReadUint64Bytes(lenBuf)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read packet length")
+	}
+
+	if length > uint64(maxSize) {
+		return nil, errors.Wrapf(ErrPacketTooLarge, "%d bytes", length)
+	}
+
+	packet := make([]byte, length)
+
+	if _, err = io.ReadFull(r, packet); err != nil {
+		return nil, noEOF(err)
+	}
+
+	return packet, nil
+}
+
+// noEOF returns io.ErrUnexpectedEOF instead of io.EOF in the middle of packet.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
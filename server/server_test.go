@@ -0,0 +1,352 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoWebProd/msgp/msgp"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+func args(values ...interface{}) msgp.Raw {
+	b, err := msgp.AppendIntf(nil, values)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+// serve starts server on random port of localhost and returns its address.
+func serve(t *testing.T, s *Server) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- s.Serve(l)
+	}()
+
+	t.Cleanup(func() {
+		s.Close()
+
+		if err := <-done; err != ErrServerClosed {
+			t.Errorf("Unexpected error of Serve: %v", err)
+		}
+	})
+
+	return l.Addr().String()
+}
+
+func connect(t *testing.T, addr string) *tarantool.Connection {
+	conn, err := tarantool.Connect(addr, tarantool.Opts{
+		Timeout:    time.Second,
+		User:       "test",
+		Pass:       "test",
+		SkipSchema: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func auth(user string) (string, bool) {
+	return "test", user == "test"
+}
+
+// checker checks responses, its methods accept results of requests as is.
+type checker struct {
+	t *testing.T
+}
+
+func (c checker) values(resp tarantool.Response, err error) []interface{} {
+	t := c.t
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("Request failed: %s", err.Error())
+	}
+
+	defer resp.Release()
+
+	if resp.Code != tarantool.OkCode {
+		t.Fatalf("Request failed: %s (0x%x)", resp.Error, resp.Code)
+	}
+
+	v, err := resp.Values()
+	if err != nil {
+		t.Fatalf("Failed to decode response: %s", err.Error())
+	}
+
+	return v
+}
+
+func (c checker) code(resp tarantool.Response, err error) uint32 {
+	t := c.t
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("Request failed: %s", err.Error())
+	}
+
+	resp.Release()
+
+	return resp.Code
+}
+
+func TestMux(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc("sum", func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+		sum := int64(0)
+
+		for _, arg := range args {
+			n, ok := arg.(int64)
+			if !ok {
+				return nil, Error(tarantool.ErrIllegalParams, "%v is not a number", arg)
+			}
+
+			sum += n
+		}
+
+		return []interface{}{sum}, nil
+	})
+	mux.HandleFunc("fail", func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	mux.HandleEval("return ...", func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+		return args, nil
+	})
+
+	conn := connect(t, serve(t, &Server{Handler: mux, Auth: auth}))
+	check := checker{t}
+
+	if c := check.code(conn.Ping()); c != tarantool.OkCode {
+		t.Errorf("Ping failed: 0x%x", c)
+	}
+
+	if v := check.values(conn.Call17("sum", args(1, 2, 3))); !reflect.DeepEqual(v, []interface{}{int64(6)}) {
+		t.Errorf("Unexpected call17 result %v", v)
+	}
+
+	if v := check.values(conn.Call("sum", args(1, 2))); !reflect.DeepEqual(v, []interface{}{[]interface{}{int64(3)}}) {
+		t.Errorf("Unexpected call result %v", v)
+	}
+
+	v := check.values(conn.Eval("return ...", args("a", []interface{}{"b"}, map[string]interface{}{"c": 1})))
+	if expected := []interface{}{"a", []interface{}{"b"}, map[interface{}]interface{}{"c": int64(1)}}; !reflect.DeepEqual(v, expected) {
+		t.Errorf("Unexpected eval result %v", v)
+	}
+
+	resp, err := conn.Call17("sum", args("one"))
+	if err != nil || resp.Code != tarantool.ErrIllegalParams || resp.Error != "one is not a number" {
+		t.Errorf("Unexpected error of call %v: %s (0x%x)", err, resp.Error, resp.Code)
+	}
+
+	resp.Release()
+
+	resp, err = conn.Call17("fail", args())
+	if err != nil || resp.Code != tarantool.ErrProcLua || resp.Error != "failed" {
+		t.Errorf("Unexpected error of call %v: %s (0x%x)", err, resp.Error, resp.Code)
+	}
+
+	resp.Release()
+
+	if c := check.code(conn.Call17("unknown", args())); c != tarantool.ErrNoSuchProc {
+		t.Errorf("Unexpected code of unknown function 0x%x", c)
+	}
+
+	if c := check.code(conn.Eval("return 1", args())); c != tarantool.ErrProcLua {
+		t.Errorf("Unexpected code of unknown eval 0x%x", c)
+	}
+
+	if c := check.code(conn.Insert(512, args(1))); c != tarantool.ErrUnknownRequestType {
+		t.Errorf("Unexpected code of unhandled request 0x%x", c)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	requests := make(chan *Request, 1)
+
+	mux := NewMux()
+	mux.Handle(tarantool.SelectRequest, HandlerFunc(func(ctx context.Context, req *Request) (Response, error) {
+		key, _, err := tarantool.Decode(req.Key)
+		if err != nil {
+			return Response{}, err
+		}
+
+		requests <- req
+
+		return Values([]interface{}{req.Space, req.Index, req.Offset, req.Limit, req.Iterator, key, req.User})
+	}))
+	mux.Handle(tarantool.UpdateRequest, HandlerFunc(func(ctx context.Context, req *Request) (Response, error) {
+		ops, _, err := tarantool.Decode(req.Ops)
+		if err != nil {
+			return Response{}, err
+		}
+
+		return Values(ops.([]interface{})...)
+	}))
+
+	conn := connect(t, serve(t, &Server{Handler: mux, Auth: auth}))
+	check := checker{t}
+
+	v := check.values(conn.Select(512, 1, 2, 3, tarantool.IterGe, args("key")))
+	if s := fmt.Sprint(v); s != "[[512 1 2 3 5 [key] test]]" {
+		t.Errorf("Unexpected select result %s", s)
+	}
+
+	if req := <-requests; req.String() != "select space 512 index 1" || req.RemoteAddr == nil {
+		t.Errorf("Unexpected request %s from %v", req, req.RemoteAddr)
+	}
+
+	ops := args([]interface{}{"=", 1, "one"})
+	if v := check.values(conn.Update(512, 0, args(1), ops)); !reflect.DeepEqual(v, []interface{}{[]interface{}{"=", int64(1), "one"}}) {
+		t.Errorf("Unexpected update result %v", v)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	s := &Server{Handler: NewMux(), Auth: auth, UUID: tarantool.UUID{1, 2, 3}, Version: "Tarantool 3.0.0 (Binary)"}
+	addr := serve(t, s)
+
+	conn := connect(t, addr)
+
+	if conn.Greeting.UUID != s.UUID || !strings.HasPrefix(conn.Greeting.Version, "Tarantool 3.0.0 (Binary) ") {
+		t.Errorf("Unexpected greeting %+v", conn.Greeting)
+	}
+
+	_, err := tarantool.Connect(addr, tarantool.Opts{User: "test", Pass: "wrong", SkipSchema: true})
+	if tntErr := (tarantool.Error{}); !errors.As(err, &tntErr) || tntErr.Code != tarantool.ErrPasswordMismatch {
+		t.Errorf("Unexpected error of wrong password: %v", err)
+	}
+
+	_, err = tarantool.Connect(addr, tarantool.Opts{User: "unknown", Pass: "test", SkipSchema: true})
+	if tntErr := (tarantool.Error{}); !errors.As(err, &tntErr) || tntErr.Code != tarantool.ErrNoSuchUser {
+		t.Errorf("Unexpected error of unknown user: %v", err)
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	var started sync.WaitGroup
+
+	started.Add(2)
+
+	all := make(chan struct{})
+
+	go func() {
+		started.Wait()
+		close(all)
+	}()
+
+	mux := NewMux()
+	mux.HandleFunc("wait", func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+		started.Done()
+
+		// both requests must be handled concurrently to pass
+		select {
+		case <-all:
+			return []interface{}{true}, nil
+		case <-time.After(time.Second):
+			return nil, errors.New("timeout")
+		}
+	})
+
+	conn := connect(t, serve(t, &Server{Handler: mux, Auth: auth, MaxInFlight: 2}))
+	check := checker{t}
+
+	first := conn.Call17Async("wait", args())
+	second := conn.Call17Async("wait", args())
+
+	for _, fut := range []*tarantool.Future{first, second} {
+		if v := check.values(fut.Get()); !reflect.DeepEqual(v, []interface{}{true}) {
+			t.Errorf("Unexpected result %v", v)
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	canceled := make(chan struct{})
+
+	mux := NewMux()
+	mux.HandleFunc("block", func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+		<-ctx.Done()
+		close(canceled)
+
+		return nil, ctx.Err()
+	})
+
+	s := &Server{Handler: mux, Auth: auth, MaxInFlight: 1}
+	conn := connect(t, serve(t, s))
+
+	fut := conn.Call17Async("block", args())
+
+	// wait for request to be sent
+	time.Sleep(50 * time.Millisecond)
+
+	if err := s.Close(); err != nil {
+		t.Errorf("Failed to close server: %s", err.Error())
+	}
+
+	select {
+	case <-canceled:
+	default:
+		t.Errorf("Context of request is not canceled on close")
+	}
+
+	// error of handler could be sent before connection is closed
+	if resp, err := fut.Get(); err == nil && resp.Code == tarantool.OkCode {
+		t.Errorf("Request succeeded after close")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+
+	if err := s.Serve(l); err != ErrServerClosed {
+		t.Errorf("Unexpected error of Serve after close: %v", err)
+	}
+}
+
+func TestReadPacket(t *testing.T) {
+	body := bytes.Repeat([]byte{0xc0}, 3)
+
+	for _, prefix := range []string{"03", "cc03", "cd0003", "ce00000003", "cf0000000000000003"} {
+		b, _ := hex.DecodeString(prefix)
+
+		packet, err := ReadPacket(bufio.NewReader(bytes.NewReader(append(b, body...))), 3)
+		if err != nil || !bytes.Equal(packet, body) {
+			t.Errorf("Unexpected packet %x with length %s: %v", packet, prefix, err)
+		}
+	}
+
+	if _, err := ReadPacket(bufio.NewReader(bytes.NewReader([]byte{0xce, 0xff, 0xff, 0xff, 0xff})), 3); !errors.Is(err, ErrPacketTooLarge) {
+		t.Errorf("Unexpected error of large packet: %v", err)
+	}
+
+	if _, err := ReadPacket(bufio.NewReader(bytes.NewReader([]byte{0x03, 0xc0})), 3); err != io.ErrUnexpectedEOF {
+		t.Errorf("Unexpected error of truncated packet: %v", err)
+	}
+
+	if _, err := ReadPacket(bufio.NewReader(bytes.NewReader(nil)), 3); err != io.EOF {
+		t.Errorf("Unexpected error of empty stream: %v", err)
+	}
+}
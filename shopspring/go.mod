@@ -0,0 +1,17 @@
+module gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/shopspring
+
+go 1.18
+
+require (
+	github.com/GoWebProd/msgp v1.2.4
+	github.com/shopspring/decimal v1.3.1
+	gitlab.corp.mail.ru/icqweb/go/go-tarantool.git v0.0.0
+)
+
+require (
+	github.com/GoWebProd/gip v0.0.0-20211004204909-3ddd41d029c0 // indirect
+	github.com/philhofer/fwd v1.1.2-0.20210722190033-5c56ac6d0bb9 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+)
+
+replace gitlab.corp.mail.ru/icqweb/go/go-tarantool.git => ..
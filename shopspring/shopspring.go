@@ -0,0 +1,24 @@
+// Package shopspring converts tarantool decimals to and from
+// github.com/shopspring/decimal ones. It is separate module, so the client
+// itself doesn't depend on decimal library:
+//
+//	price := decimal.RequireFromString("12.34")
+//	resp, err := conn.Insert(space, []interface{}{id, shopspring.ToTarantool(price)})
+//
+// Both decimals are coefficient * 10^exponent, so conversions are exact.
+package shopspring
+
+import (
+	"github.com/shopspring/decimal"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+// ToTarantool converts decimal to tarantool one.
+func ToTarantool(d decimal.Decimal) tarantool.Decimal {
+	return tarantool.NewDecimal(d.Coefficient(), d.Exponent())
+}
+
+// FromTarantool converts tarantool decimal to decimal.
+func FromTarantool(d tarantool.Decimal) decimal.Decimal {
+	return decimal.NewFromBigInt(d.Coefficient(), d.Exponent())
+}
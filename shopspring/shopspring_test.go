@@ -0,0 +1,58 @@
+package shopspring
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/shopspring/decimal"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+func TestConversion(t *testing.T) {
+	for _, s := range []string{"0", "12.34", "-0.001", "1e30", "-123456789012345678901234567890.5", "100"} {
+		d := decimal.RequireFromString(s)
+
+		td := ToTarantool(d)
+		if td.String() != d.String() {
+			t.Errorf("Unexpected tarantool decimal %s of %s", td, d)
+		}
+
+		// the same value is decoded from msgpack
+		b, err := td.MarshalMsg(nil)
+		if err != nil {
+			t.Fatalf("Failed to encode %s: %s", td, err.Error())
+		}
+
+		var decoded tarantool.Decimal
+
+		if _, err = decoded.UnmarshalMsg(b); err != nil {
+			t.Fatalf("Failed to decode %s: %s", td, err.Error())
+		}
+
+		if back := FromTarantool(decoded); !back.Equal(d) {
+			t.Errorf("Decimal %s is converted back to %s", d, back)
+		}
+
+		parsed, err := tarantool.ParseDecimal(d.String())
+		if err != nil || !FromTarantool(parsed).Equal(d) {
+			t.Errorf("Unexpected parsed decimal %s of %s: %v", parsed, d, err)
+		}
+	}
+}
+
+func ExampleToTarantool() {
+	price := decimal.RequireFromString("12.34").Mul(decimal.NewFromInt(3))
+	td := ToTarantool(price)
+
+	b, _ := msgp.AppendIntf(nil, []interface{}{1, &td})
+
+	v, _, _ := tarantool.Decode(b)
+	tuple := v.([]interface{})
+
+	fmt.Println(tuple[0], tuple[1])
+	fmt.Println(FromTarantool(*tuple[1].(*tarantool.Decimal)).Div(decimal.NewFromInt(3)))
+	// Output:
+	// 1 37.02
+	// 12.34
+}
@@ -0,0 +1,137 @@
+package tarantool
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/GoWebProd/msgp/msgp"
+)
+
+const (
+	boxShutdownEvent = "box.shutdown"
+
+	// serverShutdownTimeout is the default of box.ctl.set_on_shutdown_timeout,
+	// tarantool does not wait for clients longer.
+	serverShutdownTimeout = 3 * time.Second
+	drainInterval         = 10 * time.Millisecond
+)
+
+// Shutdown gracefully closes Connection.
+// New requests are rejected with ErrConnectionClosed, and Connection
+// is closed when all sent requests are completed or ctx is done.
+// In the latter case ctx.Err() is returned and the rest of requests
+// are failed with ErrConnectionClosed.
+// Requests kept in OfflineQueue are waited for too: they are sent
+// when connection is reestablished or fail after OfflineTimeout.
+func (conn *Connection) Shutdown(ctx context.Context) error {
+	conn.setDraining(ErrConnectionClosed)
+
+	err := conn.drain(ctx, true)
+
+	if cerr := conn.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (conn *Connection) setDraining(code uint32) {
+	conn.lockShards()
+
+	if conn.draining != ErrConnectionClosed {
+		conn.draining = code
+	}
+
+	conn.unlockShards()
+}
+
+// drain waits for all sent requests to be completed,
+// and for buffered ones if offline is set.
+func (conn *Connection) drain(ctx context.Context, offline bool) error {
+	t := time.NewTicker(drainInterval)
+	defer t.Stop()
+
+	for conn.hasPending(offline) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	return nil
+}
+
+func (conn *Connection) hasPending(offline bool) bool {
+	// buffered futures are moved to shards with all shard locks held,
+	// so checking the queue first doesn't miss them
+	if offline {
+		q := &conn.offline
+
+		q.mut.Lock()
+		n := len(q.futs)
+		q.mut.Unlock()
+
+		if n > 0 {
+			return true
+		}
+	}
+
+	for i := range conn.shard {
+		shard := &conn.shard[i]
+
+		shard.rmut.Lock()
+
+		for pos := range shard.requests {
+			if shard.requests[pos].first != nil {
+				shard.rmut.Unlock()
+
+				return true
+			}
+		}
+
+		shard.rmut.Unlock()
+	}
+
+	return false
+}
+
+// watch subscribes to updates of tarantool event.
+// Tarantool sends next update only after previous one is acknowledged
+// by the same request. Servers without watchers support respond with
+// error, which is dropped as there is no future with zero request id.
+func (conn *Connection) watch(key string) {
+	conn.sendOneway(request{
+		requestCode: WatchRequest,
+
+		function: key,
+	})
+}
+
+func (conn *Connection) event(resp *Response, c net.Conn) {
+	if resp.event == boxShutdownEvent {
+		if shutdown, _, err := msgp.ReadBoolBytes(resp.Data); err == nil && shutdown {
+			go conn.serverShutdown(c)
+
+			return
+		}
+	}
+
+	conn.watch(resp.event)
+}
+
+// serverShutdown drains connection when tarantool announces graceful
+// shutdown, then reconnects (if enabled) or closes it.
+func (conn *Connection) serverShutdown(c net.Conn) {
+	conn.opts.Logger.Info("tarantool: server is shutting down", "addr", conn.addr)
+
+	conn.setDraining(ErrConnectionNotReady)
+
+	// buffered requests wait for the next server
+	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	conn.drain(ctx, false)
+	cancel()
+
+	conn.reconnect(ClientError{ErrConnectionNotReady, "server is shutting down"}, c)
+}
@@ -0,0 +1,191 @@
+package tarantool
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/GoWebProd/gip/fasttime"
+)
+
+// latencyBuckets are upper bounds of request latency histogram buckets.
+var latencyBuckets = [...]time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+const maxRequestCode = 128
+
+// Stats is a snapshot of Connection counters.
+type Stats struct {
+	// Requests is amount of sent requests by request code.
+	Requests map[int32]uint64
+	// Errors is amount of failed requests by error code,
+	// both tarantool and client ones.
+	Errors map[uint32]uint64
+	// Latency contains histograms of request latency by request code.
+	Latency map[int32]Histogram
+	// InFlight is amount of requests waiting for response.
+	InFlight int64
+	// QueueDepth is amount of requests waiting to be written.
+	QueueDepth int
+	// Reconnects is amount of successful reconnects.
+	Reconnects uint64
+	// BytesRead and BytesWritten are amounts of bytes transferred through socket.
+	BytesRead    uint64
+	BytesWritten uint64
+	// Timeouts is amount of requests failed by client timeout.
+	Timeouts uint64
+}
+
+// Histogram is a latency histogram.
+type Histogram struct {
+	// Buckets are upper bounds of buckets.
+	Buckets []time.Duration
+	// Counts are cumulative counts of requests per bucket,
+	// the last one (+Inf bucket) is equal to Count.
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+type histogram struct {
+	counts [len(latencyBuckets) + 1]uint64
+	sum    int64
+}
+
+type connStats struct {
+	requests   [maxRequestCode]uint64
+	latency    [maxRequestCode]histogram
+	inflight   int64
+	reconnects uint64
+	read       uint64
+	written    uint64
+	timeouts   uint64
+
+	errmut sync.Mutex
+	errors map[uint32]uint64
+}
+
+// Stats returns snapshot of Connection counters.
+func (conn *Connection) Stats() Stats {
+	st := &conn.stats
+
+	stats := Stats{
+		Requests:     make(map[int32]uint64),
+		Errors:       make(map[uint32]uint64),
+		Latency:      make(map[int32]Histogram),
+		InFlight:     atomic.LoadInt64(&st.inflight),
+		QueueDepth:   conn.queueDepth(),
+		Reconnects:   atomic.LoadUint64(&st.reconnects),
+		BytesRead:    atomic.LoadUint64(&st.read),
+		BytesWritten: atomic.LoadUint64(&st.written),
+		Timeouts:     atomic.LoadUint64(&st.timeouts),
+	}
+
+	for code := range st.requests {
+		if n := atomic.LoadUint64(&st.requests[code]); n > 0 {
+			stats.Requests[int32(code)] = n
+		}
+
+		h := &st.latency[code]
+		hist := Histogram{
+			Buckets: append([]time.Duration(nil), latencyBuckets[:]...),
+			Counts:  make([]uint64, len(h.counts)),
+			Sum:     time.Duration(atomic.LoadInt64(&h.sum)),
+		}
+
+		for i := range h.counts {
+			hist.Count += atomic.LoadUint64(&h.counts[i])
+			hist.Counts[i] = hist.Count
+		}
+
+		if hist.Count > 0 {
+			stats.Latency[int32(code)] = hist
+		}
+	}
+
+	st.errmut.Lock()
+	for code, n := range st.errors {
+		stats.Errors[code] = n
+	}
+	st.errmut.Unlock()
+
+	return stats
+}
+
+func (st *connStats) sent(fut *Future) {
+	if code := fut.request.requestCode; code >= 0 && code < maxRequestCode {
+		atomic.AddUint64(&st.requests[code], 1)
+	}
+
+	atomic.AddInt64(&st.inflight, 1)
+
+	fut.start = fasttime.NowNano()
+}
+
+func (st *connStats) completed(fut *Future) {
+	if fut.start != 0 {
+		atomic.AddInt64(&st.inflight, -1)
+
+		if code := fut.request.requestCode; code >= 0 && code < maxRequestCode {
+			st.latency[code].observe(fasttime.NowNano() - fut.start)
+		}
+	}
+
+	var code uint32
+
+	switch err := fut.err.(type) {
+	case nil:
+		if fut.resp.Code == OkCode {
+			return
+		}
+
+		code = fut.resp.Code
+	case ClientError:
+		code = err.Code
+	case Error:
+		code = err.Code
+	default:
+		// network failure
+		code = ErrConnectionClosed
+	}
+
+	if code == ErrTimeouted {
+		atomic.AddUint64(&st.timeouts, 1)
+	}
+
+	st.errmut.Lock()
+
+	if st.errors == nil {
+		st.errors = make(map[uint32]uint64)
+	}
+
+	st.errors[code]++
+
+	st.errmut.Unlock()
+}
+
+func (h *histogram) observe(d int64) {
+	i := 0
+	for i < len(latencyBuckets) && d > int64(latencyBuckets[i]) {
+		i++
+	}
+
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sum, d)
+}
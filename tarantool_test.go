@@ -1,9 +1,15 @@
 package tarantool
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -661,6 +667,9 @@ func TestSchema(t *testing.T) {
 
 	// Schema
 	schema := conn.Schema
+	if schema != conn.GetSchema() {
+		t.Fatalf("Schema field differs from GetSchema")
+	}
 	if schema.SpacesById == nil {
 		t.Fatalf("schema.SpacesById is nil")
 	}
@@ -863,3 +872,1189 @@ func TestComplexStructs(t *testing.T) {
 		return
 	}
 }
+
+func TestFutureCombinators(t *testing.T) {
+	conn, err := Connect(server, opts)
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	var fs [N]*Future
+
+	for i := range fs {
+		fs[i] = conn.SelectAsync(spaceNo, indexNo, 0, 1, IterEq, UintKey{1111})
+	}
+
+	if i := WaitAny(fs[:]...); i < 0 || i >= N {
+		t.Fatalf("WaitAny returned bad position: %d", i)
+	}
+
+	WaitAll(fs[:]...)
+
+	for i := range fs {
+		select {
+		case <-fs[i].Done():
+		default:
+			t.Fatalf("future %d is not done after WaitAll", i)
+		}
+
+		resp, err := fs[i].Get()
+		if err != nil {
+			t.Fatalf("Failed to Select: %s", err.Error())
+		}
+
+		resp.Release()
+	}
+
+	done := make(chan error, 1)
+
+	conn.PingAsync().OnComplete(func(resp Response, err error) {
+		resp.Release()
+
+		done <- err
+	})
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatalf("Failed to Ping: %s", err.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("OnComplete callback was not called")
+	}
+}
+
+func TestFutureHooksRace(t *testing.T) {
+	conn, err := Connect(server, opts)
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	// subscribers are registered while responses arrive,
+	// none of them should miss completion
+	for i := 0; i < N; i++ {
+		done := make(chan error, 1)
+
+		fut := conn.PingAsync()
+		fut.OnComplete(func(resp Response, err error) {
+			resp.Release()
+
+			done <- err
+		})
+
+		fut = conn.PingAsync()
+		ch := fut.Done()
+
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatalf("Done is not closed at iteration %d", i)
+		}
+
+		resp, err := fut.Get()
+		if err != nil {
+			t.Fatalf("Failed to Ping: %s", err.Error())
+		}
+
+		resp.Release()
+
+		select {
+		case err = <-done:
+			if err != nil {
+				t.Fatalf("Failed to Ping: %s", err.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("OnComplete callback is not called at iteration %d", i)
+		}
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	limited := opts
+	limited.MaxInFlight = 1
+	limited.RLimitAction = RLimitDrop
+
+	conn, err := Connect(server, limited)
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	var fs [N]*Future
+
+	for i := range fs {
+		fs[i] = conn.PingAsync()
+	}
+
+	dropped := 0
+
+	for i := range fs {
+		resp, err := fs[i].Get()
+		if err != nil {
+			if cerr, ok := err.(ClientError); !ok || cerr.Code != ErrRateLimited {
+				t.Fatalf("Unexpected error: %s", err.Error())
+			}
+
+			dropped++
+		}
+
+		resp.Release()
+	}
+
+	if dropped == 0 {
+		t.Fatalf("No requests were rate limited")
+	}
+
+	if _, err = conn.Ping(); err != nil {
+		t.Fatalf("Failed to Ping after rate limiting: %s", err.Error())
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	conn, err := Connect(server, opts)
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+		return
+	}
+
+	var fs [N]*Future
+
+	for i := range fs {
+		fs[i] = conn.PingAsync()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err = conn.Shutdown(ctx); err != nil {
+		t.Fatalf("Failed to Shutdown: %s", err.Error())
+	}
+
+	for i := range fs {
+		resp, err := fs[i].Get()
+		if err != nil {
+			t.Fatalf("Request %d is not completed before Shutdown: %s", i, err.Error())
+		}
+
+		resp.Release()
+	}
+
+	_, err = conn.Ping()
+	if cerr, ok := err.(ClientError); !ok || cerr.Code != ErrConnectionClosed {
+		t.Fatalf("Expected ErrConnectionClosed after Shutdown but got: %v", err)
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	var calls []string
+
+	intercepted := opts
+	intercepted.Interceptors = []Interceptor{
+		func(ctx context.Context, req Request, next Invoker) (Response, error) {
+			calls = append(calls, req.Name())
+
+			return next(ctx, req)
+		},
+		func(ctx context.Context, req Request, next Invoker) (Response, error) {
+			if req.Code() == DeleteRequest && req.SpaceName() == spaceName {
+				return Response{}, ClientError{ErrRateLimited, "deletes are disabled"}
+			}
+
+			return next(ctx, req)
+		},
+	}
+
+	conn, err := Connect(server, intercepted)
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	calls = calls[:0]
+
+	resp, err := conn.Replace(spaceNo, &Tuple{Id: 1, Msg: "hello", Name: "world"})
+	if err != nil {
+		t.Fatalf("Failed to Replace: %s", err.Error())
+	}
+
+	resp.Release()
+
+	resp, err = conn.SelectAsync(spaceNo, indexNo, 0, 1, IterEq, UintKey{1}).Get()
+	if err != nil {
+		t.Fatalf("Failed to Select: %s", err.Error())
+	}
+
+	resp.Release()
+
+	_, err = conn.Delete(spaceNo, indexNo, UintKey{1})
+	if cerr, ok := err.(ClientError); !ok || cerr.Code != ErrRateLimited {
+		t.Fatalf("Expected Delete to be rejected by interceptor but got: %v", err)
+	}
+
+	if strings.Join(calls, ",") != "replace,select,delete" {
+		t.Fatalf("Unexpected intercepted calls: %v", calls)
+	}
+}
+
+type testLogger struct {
+	sync.Mutex
+	entries []string
+}
+
+func (l *testLogger) log(level, msg string, args ...interface{}) {
+	l.Lock()
+	l.entries = append(l.entries, level+" "+msg+" "+fmt.Sprint(args...))
+	l.Unlock()
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) { l.log("DEBUG", msg, args...) }
+func (l *testLogger) Info(msg string, args ...interface{})  { l.log("INFO", msg, args...) }
+func (l *testLogger) Warn(msg string, args ...interface{})  { l.log("WARN", msg, args...) }
+func (l *testLogger) Error(msg string, args ...interface{}) { l.log("ERROR", msg, args...) }
+
+func TestLogger(t *testing.T) {
+	logger := &testLogger{}
+
+	_, err := Connect("127.0.0.1:1", Opts{SkipSchema: true, Logger: logger})
+	if err == nil {
+		t.Fatalf("Connect should fail")
+	}
+
+	logger.Lock()
+	defer logger.Unlock()
+
+	if len(logger.entries) != 1 {
+		t.Fatalf("Unexpected log entries: %v", logger.entries)
+	}
+
+	if e := logger.entries[0]; !strings.HasPrefix(e, "WARN tarantool: connect failed") || !strings.Contains(e, "127.0.0.1:1") {
+		t.Errorf("Unexpected log entry: %s", e)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	logger := &testLogger{}
+
+	conn, err := Connect("127.0.0.1:1", Opts{
+		Reconnect:  time.Hour,
+		SkipSchema: true,
+		Logger:     logger,
+		Retry:      RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+	defer conn.Close()
+
+	retries := func() int {
+		logger.Lock()
+		defer logger.Unlock()
+
+		n := 0
+		for _, e := range logger.entries {
+			if strings.HasPrefix(e, "DEBUG tarantool: retrying request") {
+				n++
+			}
+		}
+
+		logger.entries = logger.entries[:0]
+
+		return n
+	}
+
+	retries()
+
+	if _, err = conn.Ping(); err == nil {
+		t.Fatalf("Ping should fail")
+	}
+	if n := retries(); n != 2 {
+		t.Errorf("Ping should be retried twice, got %d", n)
+	}
+
+	if _, err = conn.Insert(spaceNo, Iface([]interface{}{uint(1)})); err == nil {
+		t.Fatalf("Insert should fail")
+	}
+	if n := retries(); n != 0 {
+		t.Errorf("Insert should not be retried, got %d", n)
+	}
+
+	ctx := WithRetry(context.Background(), RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond})
+
+	if _, err = conn.Do(ctx, NewCall17Request("simple_incr", Iface([]interface{}{1}))); err == nil {
+		t.Fatalf("Call17 should fail")
+	}
+	if n := retries(); n != 0 {
+		t.Errorf("Call17 should not be retried, got %d", n)
+	}
+
+	if _, err = conn.Do(ctx, NewCall17Request("simple_incr", Iface([]interface{}{1})).AsIdempotent()); err == nil {
+		t.Fatalf("Call17 should fail")
+	}
+	if n := retries(); n != 1 {
+		t.Errorf("idempotent Call17 should be retried once, got %d", n)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	// server which never responds
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			c.Write(make([]byte, 128))
+			go io.Copy(io.Discard, c)
+		}
+	}()
+
+	logger := &testLogger{}
+
+	conn, err := Connect(l.Addr().String(), Opts{
+		SkipSchema:   true,
+		Logger:       logger,
+		PingInterval: 20 * time.Millisecond,
+		PingFailures: 2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+	defer conn.Close()
+
+	select {
+	case <-conn.control:
+	case <-time.After(time.Second):
+		t.Fatalf("Connection should be closed after failed health checks")
+	}
+
+	_, err = conn.Ping()
+	if cerr, ok := err.(ClientError); !ok || cerr.Code != ErrConnectionClosed {
+		t.Errorf("Ping should fail with ErrConnectionClosed, got %v", err)
+	}
+
+	logger.Lock()
+	defer logger.Unlock()
+
+	failures := 0
+	for _, e := range logger.entries {
+		if strings.HasPrefix(e, "WARN tarantool: health check failed") {
+			failures++
+		}
+	}
+
+	if failures != 2 {
+		t.Errorf("Expected 2 failed health checks, got %d: %v", failures, logger.entries)
+	}
+}
+
+// servePings accepts connections and responds to ping requests only.
+func servePings(l net.Listener) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		go func(c net.Conn) {
+			defer c.Close()
+
+			c.Write(make([]byte, 128))
+
+			header := make([]byte, 14)
+
+			for {
+				if _, err := io.ReadFull(c, header); err != nil {
+					return
+				}
+
+				length := int(header[1])<<24 | int(header[2])<<16 | int(header[3])<<8 | int(header[4])
+				if _, err := io.CopyN(io.Discard, c, int64(length-9)); err != nil {
+					return
+				}
+
+				if header[7] != PingRequest {
+					continue
+				}
+
+				resp := []byte{0xce, 0, 0, 0, 10, 0x82, KeyCode, 0, KeySync, 0xce, 0, 0, 0, 0, 0x80}
+				copy(resp[10:14], header[10:14])
+
+				c.Write(resp)
+			}
+		}(c)
+	}
+}
+
+func TestOfflineQueue(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	conn, err := Connect(addr, Opts{
+		Reconnect:      20 * time.Millisecond,
+		SkipSchema:     true,
+		PingInterval:   -1,
+		OfflineQueue:   2,
+		OfflineTimeout: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+	defer conn.Close()
+
+	// expired
+	fut := conn.PingAsync()
+	if _, err = fut.Get(); err == nil || err.(ClientError).Code != ErrTimeouted {
+		t.Errorf("Ping should fail with ErrTimeouted, got %v", err)
+	}
+
+	futs := []*Future{conn.PingAsync(), conn.PingAsync(), conn.PingAsync()}
+
+	// queue is full
+	if _, err = futs[2].Get(); err == nil || err.(ClientError).Code != ErrConnectionNotReady {
+		t.Errorf("Ping should fail with ErrConnectionNotReady, got %v", err)
+	}
+
+	if l, err = net.Listen("tcp", addr); err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+
+	go servePings(l)
+
+	for _, fut := range futs[:2] {
+		resp, err := fut.Get()
+		if err != nil {
+			t.Errorf("Buffered ping failed: %s", err.Error())
+
+			continue
+		}
+
+		resp.Release()
+	}
+}
+
+func TestShutdownOffline(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	conn, err := Connect(addr, Opts{
+		Reconnect:      20 * time.Millisecond,
+		SkipSchema:     true,
+		PingInterval:   -1,
+		OfflineQueue:   2,
+		OfflineTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+	defer conn.Close()
+
+	fut := conn.PingAsync()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+
+	go func() { done <- conn.Shutdown(ctx) }()
+
+	time.Sleep(50 * time.Millisecond)
+
+	select {
+	case err = <-done:
+		t.Fatalf("Shutdown returned while request is buffered: %v", err)
+	default:
+	}
+
+	_, err = conn.Ping()
+	if cerr, ok := err.(ClientError); !ok || cerr.Code != ErrConnectionClosed {
+		t.Fatalf("Expected ErrConnectionClosed while shutting down but got: %v", err)
+	}
+
+	if l, err = net.Listen("tcp", addr); err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+
+	go servePings(l)
+
+	if err = <-done; err != nil {
+		t.Fatalf("Failed to Shutdown: %s", err.Error())
+	}
+
+	resp, err := fut.Get()
+	if err != nil {
+		t.Fatalf("Buffered ping is not completed before Shutdown: %s", err.Error())
+	}
+
+	resp.Release()
+}
+
+func TestLazy(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	conn, err := Connect(addr, Opts{
+		Reconnect:    20 * time.Millisecond,
+		SkipSchema:   true,
+		PingInterval: -1,
+		Lazy:         true,
+	})
+	if err != nil {
+		t.Fatalf("Lazy connect should not fail: %s", err.Error())
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = conn.Do(ctx, NewPingRequest())
+	if cerr, ok := err.(ClientError); !ok || cerr.Code != ErrConnectionNotReady {
+		t.Errorf("Ping should fail with ErrConnectionNotReady, got %v", err)
+	}
+
+	if l, err = net.Listen("tcp", addr); err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+
+	go servePings(l)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	resp, err := conn.Do(ctx, NewPingRequest())
+	if err != nil {
+		t.Fatalf("Ping failed: %s", err.Error())
+	}
+	resp.Release()
+
+	fut := conn.DoAsync(ctx, NewPingRequest())
+	if resp, err = fut.Get(); err != nil {
+		t.Fatalf("Async ping failed: %s", err.Error())
+	}
+	resp.Release()
+
+	// the first connection is not reconnect
+	if stats := conn.Stats(); stats.Reconnects != 0 {
+		t.Errorf("Unexpected reconnects %d of the first connection", stats.Reconnects)
+	}
+}
+
+type countingListener struct {
+	net.Listener
+	accepted int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err == nil {
+		atomic.AddInt32(&l.accepted, 1)
+	}
+
+	return c, err
+}
+
+func TestSockets(t *testing.T) {
+	tl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+
+	l := &countingListener{Listener: tl}
+	defer l.Close()
+
+	go servePings(l)
+
+	conn, err := Connect(l.Addr().String(), Opts{
+		SkipSchema:   true,
+		PingInterval: -1,
+		Concurrency:  4,
+		Sockets:      3,
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+	defer conn.Close()
+
+	if n := atomic.LoadInt32(&l.accepted); n != 3 {
+		t.Errorf("Expected 3 sockets, got %d", n)
+	}
+
+	futs := make([]*Future, 16)
+	for i := range futs {
+		futs[i] = conn.PingAsync()
+	}
+
+	for _, fut := range futs {
+		resp, err := fut.Get()
+		if err != nil {
+			t.Fatalf("Ping failed: %s", err.Error())
+		}
+
+		resp.Release()
+	}
+}
+
+func TestTuples(t *testing.T) {
+	data := msgp.AppendArrayHeader(nil, 3)
+	for i := 0; i < 3; i++ {
+		data = msgp.AppendArrayHeader(data, 2)
+		data = msgp.AppendUint(data, uint(i))
+		data = msgp.AppendString(data, fmt.Sprintf("tuple %d", i))
+	}
+
+	resp := Response{Data: data}
+
+	it := resp.Tuples()
+	if it.Len() != 3 {
+		t.Fatalf("Expected 3 tuples, got %d", it.Len())
+	}
+
+	var tuples [][]byte
+
+	for it.Next() {
+		tuples = append(tuples, it.Tuple())
+	}
+
+	if err := it.Err(); err != nil {
+		t.Fatalf("Iteration failed: %s", err.Error())
+	}
+
+	if len(tuples) != 3 {
+		t.Fatalf("Expected 3 tuples, got %d", len(tuples))
+	}
+
+	key, err := tupleKey(tuples[2], []*IndexField{{Id: 1}, {Id: 0}})
+	if err != nil {
+		t.Fatalf("Failed to make key: %s", err.Error())
+	}
+
+	expected := msgp.AppendArrayHeader(nil, 2)
+	expected = msgp.AppendString(expected, "tuple 2")
+	expected = msgp.AppendUint(expected, 2)
+
+	if string(key) != string(expected) {
+		t.Errorf("Unexpected key %x, expected %x", []byte(key), expected)
+	}
+
+	resp = Response{Data: data[:len(data)-3]}
+	it = resp.Tuples()
+
+	for it.Next() {
+	}
+
+	if it.Err() == nil {
+		t.Errorf("Truncated data should fail")
+	}
+}
+
+func TestSelectAll(t *testing.T) {
+	conn, err := Connect(server, opts)
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+	defer conn.Close()
+
+	for i := uint(1000); i < 1025; i++ {
+		resp, err := conn.Replace(spaceNo, Iface([]interface{}{i, "hello", "world"}))
+		if err != nil {
+			t.Fatalf("Failed to Replace: %s", err.Error())
+		}
+		resp.Release()
+	}
+
+	for _, iter := range []uint32{IterGe, IterEq} {
+		var ids []uint64
+
+		key := UintKey{1000}
+		if iter == IterEq {
+			key = UintKey{1010}
+		}
+
+		err = conn.SelectAll(context.Background(), spaceNo, indexNo, iter, key, 10, func(tuple []byte) error {
+			_, remain, err := msgp.ReadArrayHeaderBytes(tuple)
+			if err != nil {
+				return err
+			}
+
+			id, _, err := msgp.ReadUint64Bytes(remain)
+			if err != nil {
+				return err
+			}
+
+			if id < 1025 {
+				ids = append(ids, id)
+			}
+
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("SelectAll failed: %s", err.Error())
+		}
+
+		switch {
+		case iter == IterGe && len(ids) != 25:
+			t.Errorf("Expected 25 tuples, got %v", ids)
+		case iter == IterEq && (len(ids) != 1 || ids[0] != 1010):
+			t.Errorf("Expected tuple 1010, got %v", ids)
+		}
+
+		for i := 1; i < len(ids); i++ {
+			if ids[i] <= ids[i-1] {
+				t.Errorf("Tuples are not ordered: %v", ids)
+			}
+		}
+	}
+}
+
+func TestSelectOptsEncoding(t *testing.T) {
+	for _, opts := range []SelectOpts{
+		{},
+		{FetchPos: true},
+		{After: []byte("position"), FetchPos: true},
+		{AfterTuple: UintKey{1}},
+	} {
+		req := NewSelectRequest(spaceNo, indexNo, 0, 10, IterGe, UintKey{1}).WithSelectOpts(opts)
+
+		var buf bytes.Buffer
+
+		w := msgp.NewWriter(&buf)
+		if err := req.r.EncodeMsg(w); err != nil {
+			t.Fatalf("Failed to encode: %s", err.Error())
+		}
+		w.Flush()
+
+		if buf.Len() != req.r.Msgsize() {
+			t.Errorf("Msgsize %d doesn't match encoded size %d for %+v", req.r.Msgsize(), buf.Len(), opts)
+		}
+
+		n, _, err := msgp.ReadMapHeaderBytes(buf.Bytes())
+		if err != nil {
+			t.Fatalf("Failed to decode: %s", err.Error())
+		}
+
+		if n != req.r.selectKeys() {
+			t.Errorf("Unexpected number of keys %d for %+v", n, opts)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	conn, err := Connect(server, opts)
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+	defer conn.Close()
+
+	for i := uint(2000); i < 2025; i++ {
+		resp, err := conn.Replace(spaceNo, Iface([]interface{}{i, "hello", "world"}))
+		if err != nil {
+			t.Fatalf("Failed to Replace: %s", err.Error())
+		}
+		resp.Release()
+	}
+
+	var ids []uint64
+
+	cur := conn.NewCursor(spaceNo, indexNo, 10, IterGe, UintKey{2000})
+
+	for !cur.Done() {
+		resp, err := cur.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to select page: %s", err.Error())
+		}
+
+		if resp.Code != OkCode {
+			t.Skipf("Select with position is not supported: %s", resp.Error)
+		}
+
+		it := resp.Tuples()
+		for it.Next() {
+			_, remain, _ := msgp.ReadArrayHeaderBytes(it.Tuple())
+			id, _, _ := msgp.ReadUint64Bytes(remain)
+
+			if id < 2025 {
+				ids = append(ids, id)
+			}
+		}
+
+		resp.Release()
+	}
+
+	if len(ids) != 25 || ids[0] != 2000 || ids[24] != 2024 {
+		t.Errorf("Unexpected tuples: %v", ids)
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	for _, c := range []struct {
+		s   string
+		enc string
+	}{
+		{"-12.34", "d60102012 34d"},
+		{"1", "d501001c"},
+		{"0.001", "d501031c"},
+		{"12345", "d6010012345c"},
+		{"-1.5e3", "c70301fe015d"},
+	} {
+		d, err := ParseDecimal(c.s)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %s", c.s, err.Error())
+		}
+
+		b, err := d.MarshalMsg(nil)
+		if err != nil {
+			t.Fatalf("Failed to encode %s: %s", c.s, err.Error())
+		}
+
+		if enc := fmt.Sprintf("%x", b); enc != strings.ReplaceAll(c.enc, " ", "") {
+			t.Errorf("Unexpected encoding of %s: %s", c.s, enc)
+		}
+
+		if len(b) != d.Msgsize() {
+			t.Errorf("Msgsize %d of %s doesn't match encoded size %d", d.Msgsize(), c.s, len(b))
+		}
+
+		v, _, err := msgp.ReadIntfBytes(b)
+		if err != nil {
+			t.Fatalf("Failed to decode %s: %s", c.s, err.Error())
+		}
+
+		dec, ok := v.(*Decimal)
+		if !ok {
+			t.Fatalf("Unexpected type %T of decoded %s", v, c.s)
+		}
+
+		if dec.Coefficient().Cmp(d.Coefficient()) != 0 || dec.Exponent() != d.Exponent() {
+			t.Errorf("Unexpected decoded %s: %s", c.s, dec)
+		}
+	}
+
+	d, _ := ParseDecimal("-1.5e3")
+	if s := d.String(); s != "-1500" {
+		t.Errorf("Unexpected string %s", s)
+	}
+
+	d, _ = ParseDecimal("-0.0150")
+	if s := d.String(); s != "-0.0150" {
+		t.Errorf("Unexpected string %s", s)
+	}
+
+	for _, s := range []string{"", ".", "1-2", "1e", "abc"} {
+		if _, err := ParseDecimal(s); err == nil {
+			t.Errorf("Parsing %q should fail", s)
+		}
+	}
+}
+
+func TestUUID(t *testing.T) {
+	const s = "7170b4af-c72f-4f07-8729-08fc678543a1"
+
+	u, err := ParseUUID(s)
+	if err != nil {
+		t.Fatalf("Failed to parse uuid: %s", err.Error())
+	}
+
+	if u.String() != s {
+		t.Errorf("Unexpected uuid string %s", u)
+	}
+
+	b, err := u.MarshalMsg(nil)
+	if err != nil {
+		t.Fatalf("Failed to encode uuid: %s", err.Error())
+	}
+
+	if enc := fmt.Sprintf("%x", b); enc != "d802"+strings.ReplaceAll(s, "-", "") {
+		t.Errorf("Unexpected encoding %s", enc)
+	}
+
+	if len(b) != u.Msgsize() {
+		t.Errorf("Msgsize %d doesn't match encoded size %d", u.Msgsize(), len(b))
+	}
+
+	v, _, err := msgp.ReadIntfBytes(b)
+	if err != nil {
+		t.Fatalf("Failed to decode uuid: %s", err.Error())
+	}
+
+	if dec, ok := v.(*UUID); !ok || *dec != u {
+		t.Errorf("Unexpected decoded uuid %v", v)
+	}
+
+	// uuid in string form, ie from box.info
+	var dec UUID
+
+	if _, err = dec.UnmarshalMsg(msgp.AppendString(nil, s)); err != nil || dec != u {
+		t.Errorf("Failed to decode uuid string: %v %s", err, dec)
+	}
+
+	for _, s := range []string{"", "7170b4afc72f4f07872908fc678543a1", "7170b4af-c72f-4f07-8729-08fc678543ax"} {
+		if _, err := ParseUUID(s); err == nil {
+			t.Errorf("Parsing %q should fail", s)
+		}
+	}
+
+	conn, err := Connect(server, opts)
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+	defer conn.Close()
+
+	if conn.Greeting.UUID == (UUID{}) {
+		t.Errorf("Greeting has no instance uuid: %s", conn.Greeting.Version)
+	}
+}
+
+func TestDatetime(t *testing.T) {
+	dt := NewDatetime(time.Unix(1, 0).UTC())
+
+	b, err := dt.MarshalMsg(nil)
+	if err != nil {
+		t.Fatalf("Failed to encode datetime: %s", err.Error())
+	}
+
+	if enc := fmt.Sprintf("%x", b); enc != "d7040100000000000000" {
+		t.Errorf("Unexpected encoding %s", enc)
+	}
+
+	zone := time.FixedZone("", 3*3600)
+	dt = NewDatetime(time.Date(2022, time.January, 31, 10, 20, 30, 500, zone))
+	dt.TzIndex = 1
+
+	if b, err = dt.MarshalMsg(nil); err != nil {
+		t.Fatalf("Failed to encode datetime: %s", err.Error())
+	}
+
+	if len(b) != dt.Msgsize() || len(b) != 18 {
+		t.Errorf("Msgsize %d doesn't match encoded size %d", dt.Msgsize(), len(b))
+	}
+
+	var dec Datetime
+
+	if _, err = dec.UnmarshalMsg(b); err != nil {
+		t.Fatalf("Failed to decode datetime: %s", err.Error())
+	}
+
+	if !dec.Time.Equal(dt.Time) || dec.TzIndex != 1 || dec.String() != "2022-01-31T10:20:30.0000005+03:00" {
+		t.Errorf("Unexpected decoded datetime %s", dec)
+	}
+
+	for _, c := range []struct {
+		from   string
+		adjust Adjust
+		months int64
+		to     string
+	}{
+		{"2022-01-31", NoneAdjust, 1, "2022-02-28"},
+		{"2022-01-31", ExcessAdjust, 1, "2022-03-03"},
+		{"2022-01-31", LastAdjust, 1, "2022-02-28"},
+		{"2022-02-28", NoneAdjust, 1, "2022-03-28"},
+		{"2022-02-28", LastAdjust, 1, "2022-03-31"},
+		{"2024-02-29", NoneAdjust, 12, "2025-02-28"},
+		{"2022-03-31", NoneAdjust, -1, "2022-02-28"},
+	} {
+		from, _ := time.Parse("2006-01-02", c.from)
+
+		res := NewDatetime(from).Add(Interval{Month: c.months, Adjust: c.adjust})
+		if s := res.Time.Format("2006-01-02"); s != c.to {
+			t.Errorf("%s + %d months (adjust %d) = %s, expected %s", c.from, c.months, c.adjust, s, c.to)
+		}
+	}
+
+	from, _ := time.Parse(time.RFC3339, "2022-01-01T23:00:00Z")
+
+	res := NewDatetime(from).Add(Interval{Week: 1, Day: 1, Hour: 2})
+	if s := res.Time.Format(time.RFC3339); s != "2022-01-10T01:00:00Z" {
+		t.Errorf("Unexpected sum %s", s)
+	}
+
+	if !res.Sub(Interval{Week: 1, Day: 1, Hour: 2}).Time.Equal(from) {
+		t.Errorf("Sub should revert Add")
+	}
+}
+
+func TestInterval(t *testing.T) {
+	ival := Interval{Year: 1, Day: -2, Adjust: LastAdjust}
+
+	b, err := ival.MarshalMsg(nil)
+	if err != nil {
+		t.Fatalf("Failed to encode interval: %s", err.Error())
+	}
+
+	if enc := fmt.Sprintf("%x", b); enc != "c70706030001"+"03fe"+"0802" {
+		t.Errorf("Unexpected encoding %s", enc)
+	}
+
+	if len(b) != ival.Msgsize() {
+		t.Errorf("Msgsize %d doesn't match encoded size %d", ival.Msgsize(), len(b))
+	}
+
+	v, _, err := msgp.ReadIntfBytes(b)
+	if err != nil {
+		t.Fatalf("Failed to decode interval: %s", err.Error())
+	}
+
+	if dec, ok := v.(*Interval); !ok || *dec != ival {
+		t.Errorf("Unexpected decoded interval %v", v)
+	}
+
+	sum := ival.Add(Interval{Year: 1, Hour: 3}).Sub(Interval{Day: 1})
+	if sum != (Interval{Year: 2, Day: -3, Hour: 3, Adjust: LastAdjust}) {
+		t.Errorf("Unexpected sum %+v", sum)
+	}
+
+	// adjust is sent as DT_EXCESS = 0, DT_LIMIT = 1 and DT_SNAP = 2 of c-dt
+	for _, c := range []struct {
+		ival Interval
+		enc  string
+	}{
+		{Interval{}, "c70306" + "01" + "0801"},
+		{Interval{Month: 1, Adjust: NoneAdjust}, "c70506" + "02" + "0101" + "0801"},
+		{Interval{Month: 1, Adjust: ExcessAdjust}, "c70506" + "02" + "0101" + "0800"},
+	} {
+		b, err := c.ival.MarshalMsg(nil)
+		if err != nil {
+			t.Fatalf("Failed to encode interval: %s", err.Error())
+		}
+
+		if enc := fmt.Sprintf("%x", b); enc != c.enc {
+			t.Errorf("Unexpected encoding %s of %+v, expected %s", enc, c.ival, c.enc)
+		}
+
+		if v, _, err := msgp.ReadIntfBytes(b); err != nil || *v.(*Interval) != c.ival {
+			t.Errorf("Unexpected decoded interval %v: %v", v, err)
+		}
+	}
+
+	// tarantool doesn't encode default adjust "none"
+	if err = ival.UnmarshalBinary([]byte{0x01, 0x01, 0x01}); err != nil || ival != (Interval{Month: 1, Adjust: NoneAdjust}) {
+		t.Errorf("Unexpected interval %+v without adjust: %v", ival, err)
+	}
+}
+
+func TestExtRegistry(t *testing.T) {
+	var errData []byte
+	errData = msgp.AppendMapHeader(errData, 1)
+	errData = msgp.AppendUint(errData, 0)
+	errData = msgp.AppendArrayHeader(errData, 2)
+
+	errData = msgp.AppendMapHeader(errData, 4)
+	errData = msgp.AppendUint(errData, 0)
+	errData = msgp.AppendString(errData, "ClientError")
+	errData = msgp.AppendUint(errData, 3)
+	errData = msgp.AppendString(errData, "outer")
+	errData = msgp.AppendUint(errData, 5)
+	errData = msgp.AppendUint(errData, ErrProcLua)
+	errData = msgp.AppendUint(errData, 6)
+	errData = msgp.AppendMapHeader(errData, 1)
+	errData = msgp.AppendString(errData, "reason")
+	errData = msgp.AppendString(errData, "test")
+
+	errData = msgp.AppendMapHeader(errData, 1)
+	errData = msgp.AppendUint(errData, 3)
+	errData = msgp.AppendString(errData, "inner")
+
+	dec, _ := ParseDecimal("12.5")
+	dt := NewDatetime(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	var b []byte
+	b = msgp.AppendArrayHeader(b, 1)
+	b = msgp.AppendArrayHeader(b, 5)
+	b, _ = dec.MarshalMsg(b)
+	b, _ = dt.MarshalMsg(b)
+	b, _ = msgp.AppendExtension(b, &msgp.RawExtension{Type: ErrorExtension, Data: errData})
+	b, _ = msgp.AppendExtension(b, &msgp.RawExtension{Type: 42, Data: []byte("abc")})
+	b = msgp.AppendMapHeader(b, 1)
+	b = msgp.AppendString(b, "k")
+	b = msgp.AppendInt(b, -1)
+
+	if v, _, err := Decode(b[1:]); err != nil {
+		t.Fatalf("Failed to decode tuple: %s", err.Error())
+	} else if raw, ok := v.([]interface{})[3].(*msgp.RawExtension); !ok || raw.Type != 42 || string(raw.Data) != "abc" {
+		t.Errorf("Unexpected unknown extension %#v", v.([]interface{})[3])
+	}
+
+	RegisterExt(42, func(data []byte) (interface{}, error) {
+		return string(data), nil
+	})
+
+	resp := &Response{Data: b}
+
+	values, err := resp.Values()
+	if err != nil {
+		t.Fatalf("Failed to decode response: %s", err.Error())
+	}
+
+	tuple := values[0].([]interface{})
+
+	if v, ok := tuple[0].(*Decimal); !ok || v.String() != "12.5" {
+		t.Errorf("Unexpected decimal %#v", tuple[0])
+	}
+
+	if v, ok := tuple[1].(*Datetime); !ok || !v.Time.Equal(dt.Time) {
+		t.Errorf("Unexpected datetime %#v", tuple[1])
+	}
+
+	boxErr, ok := tuple[2].(*BoxError)
+	if !ok {
+		t.Fatalf("Unexpected box error %#v", tuple[2])
+	}
+
+	if boxErr.Msg != "outer" || boxErr.Code != ErrProcLua || boxErr.Fields["reason"] != "test" ||
+		boxErr.Prev == nil || boxErr.Prev.Msg != "inner" {
+		t.Errorf("Unexpected box error %+v", boxErr)
+	}
+
+	if tuple[3] != "abc" {
+		t.Errorf("Unexpected custom extension %#v", tuple[3])
+	}
+
+	if m, ok := tuple[4].(map[interface{}]interface{}); !ok || m["k"] != int64(-1) {
+		t.Errorf("Unexpected map %#v", tuple[4])
+	}
+
+	it := resp.Tuples()
+	if !it.Next() {
+		t.Fatalf("No tuples: %v", it.Err())
+	}
+
+	if values, err := it.Values(); err != nil || len(values) != 5 {
+		t.Errorf("Unexpected tuple values %v: %v", values, err)
+	}
+
+	js, _, err := AppendJSON(nil, b)
+	if err != nil {
+		t.Fatalf("Failed to convert to json: %s", err.Error())
+	}
+
+	expected := `[["12.5","2023-01-02T03:04:05Z","outer (ClientError, 0x20)","abc",{"k":-1}]]`
+	if string(js) != expected {
+		t.Errorf("Unexpected json %s", js)
+	}
+}
@@ -0,0 +1,267 @@
+package tarantooltest
+
+import (
+	"context"
+
+	"github.com/GoWebProd/msgp/msgp"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+	"gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/server"
+)
+
+func protocolError(format string, args ...interface{}) error {
+	return server.Error(tarantool.ErrProtocol, format, args...)
+}
+
+// space returns space of request, it is called with server lock held.
+func (s *Server) space(req *server.Request) (*Space, error) {
+	if _, ok := req.Body[tarantool.KeySpaceNo]; !ok {
+		return nil, server.Error(tarantool.ErrMissingRequestField, "Missing mandatory field 'SPACE_ID' in request")
+	}
+
+	space, ok := s.spaces[req.Space]
+	if !ok {
+		return nil, server.Error(tarantool.ErrNoSuchSpace, "Space '%d' does not exist", req.Space)
+	}
+
+	return space, nil
+}
+
+// index returns index of request, it is called with server lock held.
+func (s *Server) index(req *server.Request) (*Index, error) {
+	space, err := s.space(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return space.index(req.Index)
+}
+
+// fields returns fields of array value named name, empty if raw is empty.
+func fields(raw msgp.Raw, name string) ([]msgp.Raw, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	if msgp.NextType(raw) != msgp.ArrayType {
+		return nil, protocolError("invalid %s", name)
+	}
+
+	t, err := parseTuple(raw)
+	if err != nil {
+		return nil, protocolError("invalid %s", name)
+	}
+
+	return t.fields, nil
+}
+
+// requestTuple returns tuple of array value named name.
+func requestTuple(raw msgp.Raw, name string) (*tuple, error) {
+	fields, err := fields(raw, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tuple{fields: fields}, nil
+}
+
+func appendTuples(b []byte, tuples ...*tuple) []byte {
+	b = msgp.AppendArrayHeader(b, uint32(len(tuples)))
+
+	for _, t := range tuples {
+		b = t.appendTo(b)
+	}
+
+	return b
+}
+
+func (s *Server) selectTuples(ctx context.Context, req *server.Request) (server.Response, error) {
+	var resp server.Response
+
+	key, err := fields(req.Key, "KEY")
+	if err != nil {
+		return resp, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	index, err := s.index(req)
+	if err != nil {
+		return resp, err
+	}
+
+	var after []msgp.Raw
+
+	if _, ok := req.Body[tarantool.KeyAfterPos]; ok {
+		if len(req.After) > 0 {
+			t, err := parseTuple(req.After)
+			if err != nil {
+				return resp, server.Error(tarantool.ErrIllegalParams, "Invalid position")
+			}
+
+			after = t.fields
+		}
+	} else if req.AfterTuple != nil {
+		t, err := requestTuple(req.AfterTuple, "AFTER_TUPLE")
+		if err != nil {
+			return resp, err
+		}
+
+		if err = index.check(t); err != nil {
+			return resp, err
+		}
+
+		after = index.position(t)
+	}
+
+	tuples, err := index.selectTuples(req.Iterator, key, after)
+	if err != nil {
+		return resp, err
+	}
+
+	if req.Offset >= uint32(len(tuples)) {
+		tuples = nil
+	} else {
+		tuples = tuples[req.Offset:]
+	}
+
+	if req.Limit < uint32(len(tuples)) {
+		tuples = tuples[:req.Limit]
+	}
+
+	resp.Data = appendTuples(nil, tuples...)
+
+	if req.FetchPos && len(tuples) > 0 {
+		last := &tuple{fields: index.position(tuples[len(tuples)-1])}
+		resp.Position = last.appendTo(nil)
+	}
+
+	return resp, nil
+}
+
+func (s *Server) insert(ctx context.Context, req *server.Request) (server.Response, error) {
+	t, err := requestTuple(req.Tuple, "TUPLE")
+	if err != nil {
+		return server.Response{}, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	space, err := s.space(req)
+	if err != nil {
+		return server.Response{}, err
+	}
+
+	if _, err = space.put(t, req.Code == tarantool.ReplaceRequest); err != nil {
+		return server.Response{}, err
+	}
+
+	return server.Response{Data: appendTuples(nil, t)}, nil
+}
+
+func (s *Server) delete(ctx context.Context, req *server.Request) (server.Response, error) {
+	key, err := fields(req.Key, "KEY")
+	if err != nil {
+		return server.Response{}, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	index, err := s.index(req)
+	if err != nil {
+		return server.Response{}, err
+	}
+
+	t, err := index.get(key)
+	if err != nil || t == nil {
+		return server.Response{Data: appendTuples(nil)}, err
+	}
+
+	index.space.remove(t)
+
+	return server.Response{Data: appendTuples(nil, t)}, nil
+}
+
+func (s *Server) update(ctx context.Context, req *server.Request) (server.Response, error) {
+	key, err := fields(req.Key, "KEY")
+	if err != nil {
+		return server.Response{}, err
+	}
+
+	ops, err := fields(req.Ops, "OPS")
+	if err != nil {
+		return server.Response{}, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	index, err := s.index(req)
+	if err != nil {
+		return server.Response{}, err
+	}
+
+	old, err := index.get(key)
+	if err != nil || old == nil {
+		return server.Response{Data: appendTuples(nil)}, err
+	}
+
+	t, err := index.space.update(old, ops, int64(req.IndexBase), false)
+	if err != nil {
+		return server.Response{}, err
+	}
+
+	if _, err = index.space.put(t, true); err != nil {
+		return server.Response{}, err
+	}
+
+	return server.Response{Data: appendTuples(nil, t)}, nil
+}
+
+func (s *Server) upsert(ctx context.Context, req *server.Request) (server.Response, error) {
+	t, err := requestTuple(req.Tuple, "TUPLE")
+	if err != nil {
+		return server.Response{}, err
+	}
+
+	ops, err := fields(req.Ops, "OPS")
+	if err != nil {
+		return server.Response{}, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	space, err := s.space(req)
+	if err != nil {
+		return server.Response{}, err
+	}
+
+	primary, err := space.index(0)
+	if err != nil {
+		return server.Response{}, err
+	}
+
+	if err = space.check(t); err != nil {
+		return server.Response{}, err
+	}
+
+	i, ok := primary.find(primary.key(t))
+	if !ok {
+		if _, err = space.put(t, false); err != nil {
+			return server.Response{}, err
+		}
+
+		return server.Response{Data: appendTuples(nil)}, nil
+	}
+
+	// as tarantool does, failed operations are skipped,
+	// and tuple is kept if result can't be stored
+	if updated, err := space.update(primary.tuples[i], ops, int64(req.IndexBase), true); err == nil {
+		space.put(updated, true)
+	}
+
+	return server.Response{Data: appendTuples(nil)}, nil
+}
@@ -0,0 +1,150 @@
+// Package tarantooltest provides in-memory tarantool for hermetic tests.
+//
+// Server speaks IPROTO: greeting, chap-sha1 auth, ping, select, insert,
+// replace, update, delete and upsert on in-memory spaces with tree and hash
+// indexes, and call and eval of registered Go functions. Spaces and indexes
+// are listed in _vspace and _vindex, so connections load schema as usual.
+// Access control is not checked: every user could do everything.
+package tarantooltest
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+	"gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/server"
+)
+
+// Version is tarantool version sent in greeting.
+const Version = server.DefaultVersion
+
+// Func is Go function called by call and eval requests.
+// Its results are returned to client as is.
+type Func func(args []interface{}) ([]interface{}, error)
+
+// Server is in-memory tarantool.
+type Server struct {
+	// UUID is instance uuid sent in greeting.
+	UUID tarantool.UUID
+
+	l       net.Listener
+	srv     *server.Server
+	mux     *server.Mux
+	serving chan struct{}
+
+	mutex  sync.Mutex
+	spaces map[uint32]*Space
+	names  map[string]*Space
+	users  map[string]string
+	schema uint64
+}
+
+// NewServer creates server listening addr, ie "127.0.0.1:0".
+// If addr is empty, server listens random port of localhost.
+func NewServer(addr string) (*Server, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't listen")
+	}
+
+	s := &Server{
+		l:       l,
+		mux:     server.NewMux(),
+		serving: make(chan struct{}),
+		spaces:  make(map[uint32]*Space),
+		names:   make(map[string]*Space),
+		users:   make(map[string]string),
+	}
+
+	if s.UUID, err = tarantool.NewUUID(); err != nil {
+		l.Close()
+
+		return nil, err
+	}
+
+	s.bootstrap()
+
+	s.mux.Handle(tarantool.SelectRequest, server.HandlerFunc(s.selectTuples))
+	s.mux.Handle(tarantool.InsertRequest, server.HandlerFunc(s.insert))
+	s.mux.Handle(tarantool.ReplaceRequest, server.HandlerFunc(s.insert))
+	s.mux.Handle(tarantool.UpdateRequest, server.HandlerFunc(s.update))
+	s.mux.Handle(tarantool.DeleteRequest, server.HandlerFunc(s.delete))
+	s.mux.Handle(tarantool.UpsertRequest, server.HandlerFunc(s.upsert))
+
+	s.srv = &server.Server{
+		Handler: server.HandlerFunc(s.serveIPROTO),
+		Auth:    s.password,
+		UUID:    s.UUID,
+	}
+
+	go func() {
+		defer close(s.serving)
+
+		s.srv.Serve(l)
+	}()
+
+	return s, nil
+}
+
+// Addr returns address server listens.
+func (s *Server) Addr() string {
+	return s.l.Addr().String()
+}
+
+// Close stops server and closes all its connections.
+func (s *Server) Close() error {
+	err := s.srv.Close()
+
+	<-s.serving
+
+	return err
+}
+
+// AddUser adds user authenticated with chap-sha1.
+// User guest always exists and has no password.
+func (s *Server) AddUser(name, password string) {
+	s.mutex.Lock()
+	s.users[name] = password
+	s.mutex.Unlock()
+}
+
+func (s *Server) password(user string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	password, ok := s.users[user]
+
+	return password, ok
+}
+
+// serveIPROTO passes request to mux and sets schema version of response.
+func (s *Server) serveIPROTO(ctx context.Context, req *server.Request) (server.Response, error) {
+	resp, err := s.mux.ServeIPROTO(ctx, req)
+
+	s.mutex.Lock()
+	resp.SchemaVersion = s.schema
+	s.mutex.Unlock()
+
+	return resp, err
+}
+
+// RegisterFunc registers function called by call and call17 requests.
+func (s *Server) RegisterFunc(name string, f Func) {
+	s.mux.HandleFunc(name, func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+		return f(args)
+	})
+}
+
+// RegisterEval registers function called by eval request of expr.
+// Expressions are not parsed, so expr must be equal to requested one.
+func (s *Server) RegisterEval(expr string, f Func) {
+	s.mux.HandleEval(expr, func(ctx context.Context, args []interface{}) ([]interface{}, error) {
+		return f(args)
+	})
+}
@@ -0,0 +1,324 @@
+package tarantooltest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/GoWebProd/msgp/msgp"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+func args(values ...interface{}) msgp.Raw {
+	b, err := msgp.AppendIntf(nil, values)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+func newServer(t *testing.T) *Server {
+	s, err := NewServer("")
+	if err != nil {
+		t.Fatalf("Failed to start server: %s", err.Error())
+	}
+
+	t.Cleanup(func() { s.Close() })
+
+	s.AddUser("test", "test")
+
+	space, err := s.CreateSpace(512, "test", SpaceOpts{})
+	if err != nil {
+		t.Fatalf("Failed to create space: %s", err.Error())
+	}
+
+	if _, err = space.CreateIndex(0, "primary", IndexOpts{Unique: true, Parts: []Part{{0, "unsigned"}}}); err != nil {
+		t.Fatalf("Failed to create index: %s", err.Error())
+	}
+
+	if _, err = space.CreateIndex(1, "secondary", IndexOpts{Parts: []Part{{1, "string"}}}); err != nil {
+		t.Fatalf("Failed to create index: %s", err.Error())
+	}
+
+	hash, err := s.CreateSpace(514, "hash", SpaceOpts{Format: []Field{{"id", "string"}, {"value", "any"}}})
+	if err != nil {
+		t.Fatalf("Failed to create space: %s", err.Error())
+	}
+
+	if _, err = hash.CreateIndex(0, "primary", IndexOpts{Type: "hash", Unique: true, Parts: []Part{{0, "string"}}}); err != nil {
+		t.Fatalf("Failed to create index: %s", err.Error())
+	}
+
+	return s
+}
+
+func connect(t *testing.T, s *Server) *tarantool.Connection {
+	conn, err := tarantool.Connect(s.Addr(), tarantool.Opts{
+		Timeout: time.Second,
+		User:    "test",
+		Pass:    "test",
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// checker checks responses, its methods accept results of requests as is.
+type checker struct {
+	t *testing.T
+}
+
+func (c checker) values(resp tarantool.Response, err error) []interface{} {
+	t := c.t
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("Request failed: %s", err.Error())
+	}
+
+	defer resp.Release()
+
+	if resp.Code != tarantool.OkCode {
+		t.Fatalf("Request failed: %s (0x%x)", resp.Error, resp.Code)
+	}
+
+	v, err := resp.Values()
+	if err != nil {
+		t.Fatalf("Failed to decode response: %s", err.Error())
+	}
+
+	return v
+}
+
+func (c checker) code(resp tarantool.Response, err error) uint32 {
+	t := c.t
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("Request failed: %s", err.Error())
+	}
+
+	resp.Release()
+
+	return resp.Code
+}
+
+func TestConnect(t *testing.T) {
+	s := newServer(t)
+	conn := connect(t, s)
+	check := checker{t}
+
+	if conn.Greeting.UUID != s.UUID {
+		t.Errorf("Unexpected uuid %s, expected %s", conn.Greeting.UUID, s.UUID)
+	}
+
+	spaceNo, indexNo, err := conn.Schema.ResolveSpaceIndex("test", "secondary")
+	if err != nil || spaceNo != 512 || indexNo != 1 {
+		t.Errorf("Unexpected space %d and index %d: %v", spaceNo, indexNo, err)
+	}
+
+	if space := conn.Schema.Spaces["hash"]; space == nil || space.Indexes["primary"].Type != "hash" ||
+		!space.Indexes["primary"].Unique || space.Fields["value"].Type != "any" {
+		t.Errorf("Unexpected schema of space %+v", space)
+	}
+
+	if c := check.code(conn.Ping()); c != tarantool.OkCode {
+		t.Errorf("Ping failed: 0x%x", c)
+	}
+
+	_, err = tarantool.Connect(s.Addr(), tarantool.Opts{User: "test", Pass: "wrong"})
+	if tntErr := (tarantool.Error{}); !errors.As(err, &tntErr) || tntErr.Code != tarantool.ErrPasswordMismatch {
+		t.Errorf("Unexpected error of wrong password: %v", err)
+	}
+
+	s.Close()
+
+	if _, err = conn.Ping(); err == nil {
+		t.Errorf("Ping of closed server should fail")
+	}
+}
+
+func TestCRUD(t *testing.T) {
+	s := newServer(t)
+	conn := connect(t, s)
+	check := checker{t}
+
+	for i := uint(1); i <= 5; i++ {
+		v := check.values(conn.Insert(512, args(i, fmt.Sprintf("name%d", i%3))))
+		if !reflect.DeepEqual(v, []interface{}{[]interface{}{int64(i), fmt.Sprintf("name%d", i%3)}}) {
+			t.Errorf("Unexpected insert result %v", v)
+		}
+	}
+
+	if c := check.code(conn.Insert(512, args(uint(1), "dup"))); c != tarantool.ErrTupleFound {
+		t.Errorf("Unexpected code of duplicate 0x%x", c)
+	}
+
+	if c := check.code(conn.Insert(512, args("bad", "type"))); c != tarantool.ErrFieldType {
+		t.Errorf("Unexpected code of wrong type 0x%x", c)
+	}
+
+	if c := check.code(conn.Insert(600, args(uint(1)))); c != tarantool.ErrNoSuchSpace {
+		t.Errorf("Unexpected code of unknown space 0x%x", c)
+	}
+
+	for _, tc := range []struct {
+		index, iterator, offset, limit uint32
+		key                            msgp.Raw
+		ids                            []int64
+	}{
+		{0, tarantool.IterEq, 0, 10, args(uint(3)), []int64{3}},
+		{0, tarantool.IterAll, 0, 10, args(), []int64{1, 2, 3, 4, 5}},
+		{0, tarantool.IterGt, 1, 2, args(uint(1)), []int64{3, 4}},
+		{0, tarantool.IterLe, 0, 10, args(uint(3)), []int64{3, 2, 1}},
+		{0, tarantool.IterLt, 0, 10, args(), []int64{5, 4, 3, 2, 1}},
+		{1, tarantool.IterEq, 0, 10, args("name1"), []int64{1, 4}},
+		{1, tarantool.IterReq, 0, 10, args("name2"), []int64{5, 2}},
+		{1, tarantool.IterGe, 0, 10, args("name1"), []int64{1, 4, 2, 5}},
+	} {
+		v := check.values(conn.Select(512, tc.index, tc.offset, tc.limit, tc.iterator, tc.key))
+
+		ids := make([]int64, len(v))
+		for i, tuple := range v {
+			ids[i] = tuple.([]interface{})[0].(int64)
+		}
+
+		if !reflect.DeepEqual(ids, tc.ids) && !(len(ids) == 0 && len(tc.ids) == 0) {
+			t.Errorf("Unexpected select %d of index %d by %v: %v, expected %v", tc.iterator, tc.index, tc.key, ids, tc.ids)
+		}
+	}
+
+	v := check.values(conn.Replace(512, args(uint(3), "replaced", uint(10))))
+	if !reflect.DeepEqual(v, []interface{}{[]interface{}{int64(3), "replaced", int64(10)}}) {
+		t.Errorf("Unexpected replace result %v", v)
+	}
+
+	v = check.values(conn.Update(512, 0, args(uint(3)), args(
+		[]interface{}{"+", 2, 5},
+		[]interface{}{"=", 1, "updated"},
+		[]interface{}{"!", -1, "last"},
+		[]interface{}{":", 3, 0, 1, "L"},
+	)))
+	if !reflect.DeepEqual(v, []interface{}{[]interface{}{int64(3), "updated", int64(15), "Last"}}) {
+		t.Errorf("Unexpected update result %v", v)
+	}
+
+	if c := check.code(conn.Update(512, 0, args(uint(3)), args([]interface{}{"=", 0, uint(7)}))); c != tarantool.ErrCantUpdatePrimaryKey {
+		t.Errorf("Unexpected code of primary key update 0x%x", c)
+	}
+
+	if v = check.values(conn.Update(512, 0, args(uint(30)), args([]interface{}{"=", 1, "x"}))); len(v) != 0 {
+		t.Errorf("Unexpected update of missing tuple %v", v)
+	}
+
+	check.values(conn.Upsert(512, args(uint(6), "upserted", uint(1)), args([]interface{}{"+", 2, 1})))
+	check.values(conn.Upsert(512, args(uint(6), "upserted", uint(1)), args([]interface{}{"+", 2, 1})))
+
+	v = check.values(conn.Delete(512, 0, args(uint(6))))
+	if !reflect.DeepEqual(v, []interface{}{[]interface{}{int64(6), "upserted", int64(2)}}) {
+		t.Errorf("Unexpected delete result %v", v)
+	}
+
+	if c := check.code(conn.Delete(512, 1, args("name1"))); c == tarantool.OkCode {
+		t.Errorf("Delete by non unique index should fail")
+	}
+
+	if n := s.Space("test").Len(); n != 5 {
+		t.Errorf("Unexpected number of tuples %d", n)
+	}
+
+	// hash index
+	check.values(conn.Insert(514, args("b", 2)))
+	check.values(conn.Insert(514, args("a", 1)))
+
+	if v = check.values(conn.Select(514, 0, 0, 10, tarantool.IterEq, args("a"))); len(v) != 1 {
+		t.Errorf("Unexpected select of hash index %v", v)
+	}
+
+	if c := check.code(conn.Select(514, 0, 0, 10, tarantool.IterLt, args("a"))); c != tarantool.ErrUnsupported {
+		t.Errorf("Unexpected code of unsupported iterator 0x%x", c)
+	}
+
+	v = check.values(conn.Update(514, 0, args("a"), args([]interface{}{"=", "value", "named"})))
+	if !reflect.DeepEqual(v, []interface{}{[]interface{}{"a", "named"}}) {
+		t.Errorf("Unexpected update by field name %v", v)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	s := newServer(t)
+	conn := connect(t, s)
+	check := checker{t}
+
+	for i := 0; i < 10; i++ {
+		if err := s.Space("test").Insert(uint(i), fmt.Sprintf("name%d", i%2)); err != nil {
+			t.Fatalf("Failed to insert: %s", err.Error())
+		}
+	}
+
+	cur := conn.NewCursor(512, 1, 3, tarantool.IterAll, nil)
+
+	var ids []int64
+
+	for !cur.Done() {
+		resp, err := cur.Next(context.Background())
+		for _, tuple := range check.values(resp, err) {
+			ids = append(ids, tuple.([]interface{})[0].(int64))
+		}
+	}
+
+	if expected := []int64{0, 2, 4, 6, 8, 1, 3, 5, 7, 9}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Unexpected tuples of cursor %v", ids)
+	}
+}
+
+func TestCall(t *testing.T) {
+	s := newServer(t)
+	conn := connect(t, s)
+	check := checker{t}
+
+	s.RegisterFunc("simple_incr", func(args []interface{}) ([]interface{}, error) {
+		if len(args) != 1 {
+			return nil, errors.New("one argument expected")
+		}
+
+		return []interface{}{args[0].(int64) + 1}, nil
+	})
+
+	s.RegisterEval("return box.space.test:count()", func(args []interface{}) ([]interface{}, error) {
+		return []interface{}{s.Space("test").Len()}, nil
+	})
+
+	if v := check.values(conn.Call17("simple_incr", args(1))); !reflect.DeepEqual(v, []interface{}{int64(2)}) {
+		t.Errorf("Unexpected call17 result %v", v)
+	}
+
+	if v := check.values(conn.Call("simple_incr", args(1))); !reflect.DeepEqual(v, []interface{}{[]interface{}{int64(2)}}) {
+		t.Errorf("Unexpected call result %v", v)
+	}
+
+	resp, err := conn.Call17("simple_incr", args())
+	if err != nil || resp.Code != tarantool.ErrProcLua || resp.Error != "one argument expected" {
+		t.Errorf("Unexpected error of call %v: %s (0x%x)", err, resp.Error, resp.Code)
+	}
+
+	resp.Release()
+
+	if c := check.code(conn.Call17("unknown", args())); c != tarantool.ErrNoSuchProc {
+		t.Errorf("Unexpected code of unknown function 0x%x", c)
+	}
+
+	s.Space("test").Insert(uint(1), "one")
+
+	if v := check.values(conn.Eval("return box.space.test:count()", args())); !reflect.DeepEqual(v, []interface{}{int64(1)}) {
+		t.Errorf("Unexpected eval result %v", v)
+	}
+}
@@ -0,0 +1,656 @@
+package tarantooltest
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+const (
+	vspaceId = 281
+	vindexId = 289
+)
+
+// SpaceOpts are options of space, they are returned in _vspace.
+type SpaceOpts struct {
+	// Engine is "memtx" by default, it affects nothing.
+	Engine    string
+	Temporary bool
+	// FieldCount is exact number of fields of every tuple if it is not zero.
+	FieldCount uint32
+	Format     []Field
+}
+
+// Field is format of tuple field.
+type Field struct {
+	Name string
+	Type string
+}
+
+// IndexOpts are options of index.
+type IndexOpts struct {
+	// Type is "tree" by default or "hash".
+	// Hash index supports only IterEq, IterAll and IterGt iterators.
+	Type string
+	// Unique must be set for primary index.
+	Unique bool
+	Parts  []Part
+}
+
+// Part is indexed field, Field is zero based.
+type Part struct {
+	Field uint32
+	Type  string
+}
+
+// Space is in-memory space. It is safe for concurrent use.
+type Space struct {
+	Id   uint32
+	Name string
+
+	server  *Server
+	opts    SpaceOpts
+	indexes []*Index
+}
+
+// Index is index of space.
+type Index struct {
+	Id   uint32
+	Name string
+
+	space  *Space
+	opts   IndexOpts
+	tuples []*tuple
+}
+
+// tuple is stored tuple, its fields are kept encoded as they were received.
+type tuple struct {
+	fields []msgp.Raw
+}
+
+func parseTuple(b []byte) (*tuple, error) {
+	n, remain, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tuple{fields: make([]msgp.Raw, n)}
+
+	for i := range t.fields {
+		field := remain
+
+		if remain, err = msgp.Skip(remain); err != nil {
+			return nil, err
+		}
+
+		t.fields[i] = append(msgp.Raw(nil), field[:len(field)-len(remain)]...)
+	}
+
+	return t, nil
+}
+
+func (t *tuple) appendTo(b []byte) []byte {
+	b = msgp.AppendArrayHeader(b, uint32(len(t.fields)))
+
+	for _, field := range t.fields {
+		b = append(b, field...)
+	}
+
+	return b
+}
+
+func (t *tuple) values() []interface{} {
+	values := make([]interface{}, len(t.fields))
+
+	for i, field := range t.fields {
+		values[i] = value(field)
+	}
+
+	return values
+}
+
+// bootstrap creates system spaces.
+func (s *Server) bootstrap() {
+	unsigned := Part{Type: "unsigned"}
+
+	vspace := &Space{Id: vspaceId, Name: "_vspace", server: s, opts: SpaceOpts{Engine: "sysview"}}
+	vindex := &Space{Id: vindexId, Name: "_vindex", server: s, opts: SpaceOpts{Engine: "sysview"}}
+
+	for _, space := range []*Space{vspace, vindex} {
+		s.spaces[space.Id] = space
+		s.names[space.Name] = space
+	}
+
+	vspace.indexes = []*Index{{Name: "primary", space: vspace, opts: IndexOpts{Type: "tree", Unique: true, Parts: []Part{unsigned}}}}
+	vindex.indexes = []*Index{{Name: "primary", space: vindex, opts: IndexOpts{Type: "tree", Unique: true, Parts: []Part{unsigned, {Field: 1, Type: "unsigned"}}}}}
+
+	for _, space := range []*Space{vspace, vindex} {
+		s.registerSpace(space)
+		s.registerIndex(space.indexes[0])
+	}
+}
+
+// CreateSpace creates space, it should have primary index created with CreateIndex.
+func (s *Server) CreateSpace(id uint32, name string, opts SpaceOpts) (*Space, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.spaces[id]; ok {
+		return nil, errors.Errorf("space %d already exists", id)
+	}
+
+	if _, ok := s.names[name]; ok {
+		return nil, errors.Errorf("space '%s' already exists", name)
+	}
+
+	if opts.Engine == "" {
+		opts.Engine = "memtx"
+	}
+
+	space := &Space{Id: id, Name: name, server: s, opts: opts}
+
+	s.spaces[id] = space
+	s.names[name] = space
+
+	s.registerSpace(space)
+
+	return space, nil
+}
+
+// Space returns space by name, or nil if there is no such space.
+func (s *Server) Space(name string) *Space {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.names[name]
+}
+
+func (s *Server) registerSpace(space *Space) {
+	format := make([]interface{}, len(space.opts.Format))
+
+	for i, field := range space.opts.Format {
+		format[i] = map[string]interface{}{"name": field.Name, "type": field.Type}
+	}
+
+	flags := map[string]interface{}{}
+	if space.opts.Temporary {
+		flags["temporary"] = true
+	}
+
+	s.register(vspaceId, space.Id, uint32(1), space.Name, space.opts.Engine, space.opts.FieldCount, flags, format)
+}
+
+func (s *Server) registerIndex(index *Index) {
+	parts := make([]interface{}, len(index.opts.Parts))
+
+	for i, part := range index.opts.Parts {
+		parts[i] = []interface{}{part.Field, part.Type}
+	}
+
+	s.register(vindexId, index.space.Id, index.Id, index.Name, index.opts.Type,
+		map[string]interface{}{"unique": index.opts.Unique}, parts)
+}
+
+// register puts row into system space and increments schema version.
+func (s *Server) register(space uint32, row ...interface{}) {
+	b, err := msgp.AppendIntf(nil, row)
+	if err != nil {
+		panic(err)
+	}
+
+	t, err := parseTuple(b)
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err = s.spaces[space].put(t, true); err != nil {
+		panic(err)
+	}
+
+	s.schema++
+}
+
+// CreateIndex creates index of space. The first index is primary one,
+// it must be unique. Index is built from tuples of primary index.
+func (space *Space) CreateIndex(id uint32, name string, opts IndexOpts) (*Index, error) {
+	space.server.mutex.Lock()
+	defer space.server.mutex.Unlock()
+
+	switch opts.Type {
+	case "":
+		opts.Type = "tree"
+	case "tree", "hash":
+	default:
+		return nil, errors.Errorf("unsupported index type '%s'", opts.Type)
+	}
+
+	if len(opts.Parts) == 0 {
+		return nil, errors.New("index should have parts")
+	}
+
+	if len(space.indexes) == 0 && !opts.Unique {
+		return nil, errors.New("primary index must be unique")
+	}
+
+	if opts.Type == "hash" && !opts.Unique {
+		return nil, errors.New("hash index must be unique")
+	}
+
+	for _, index := range space.indexes {
+		if index.Id == id || index.Name == name {
+			return nil, errors.Errorf("index '%s' already exists", name)
+		}
+	}
+
+	index := &Index{Id: id, Name: name, space: space, opts: opts}
+
+	if len(space.indexes) > 0 {
+		for _, t := range space.indexes[0].tuples {
+			if err := index.check(t); err != nil {
+				return nil, err
+			}
+
+			if _, ok := index.find(index.key(t)); ok && opts.Unique {
+				return nil, index.duplicate()
+			}
+
+			index.insert(t)
+		}
+	}
+
+	space.indexes = append(space.indexes, index)
+
+	space.server.registerIndex(index)
+
+	return index, nil
+}
+
+// Insert inserts tuple into space.
+func (space *Space) Insert(tuple ...interface{}) error {
+	return space.goPut(tuple, false)
+}
+
+// Replace inserts or replaces tuple of space.
+func (space *Space) Replace(tuple ...interface{}) error {
+	return space.goPut(tuple, true)
+}
+
+func (space *Space) goPut(values []interface{}, replace bool) error {
+	b, err := msgp.AppendIntf(nil, values)
+	if err != nil {
+		return errors.Wrap(err, "can't encode tuple")
+	}
+
+	t, err := parseTuple(b)
+	if err != nil {
+		return errors.Wrap(err, "can't decode tuple")
+	}
+
+	space.server.mutex.Lock()
+	defer space.server.mutex.Unlock()
+
+	_, err = space.put(t, replace)
+
+	return err
+}
+
+// Tuples returns decoded tuples of space in order of primary index.
+func (space *Space) Tuples() [][]interface{} {
+	space.server.mutex.Lock()
+	defer space.server.mutex.Unlock()
+
+	if len(space.indexes) == 0 {
+		return nil
+	}
+
+	tuples := make([][]interface{}, len(space.indexes[0].tuples))
+
+	for i, t := range space.indexes[0].tuples {
+		tuples[i] = t.values()
+	}
+
+	return tuples
+}
+
+// Len returns number of tuples of space.
+func (space *Space) Len() int {
+	space.server.mutex.Lock()
+	defer space.server.mutex.Unlock()
+
+	if len(space.indexes) == 0 {
+		return 0
+	}
+
+	return len(space.indexes[0].tuples)
+}
+
+// Truncate deletes all tuples of space.
+func (space *Space) Truncate() {
+	space.server.mutex.Lock()
+	defer space.server.mutex.Unlock()
+
+	for _, index := range space.indexes {
+		index.tuples = nil
+	}
+}
+
+// index returns index by id, it is called with server lock held.
+func (space *Space) index(id uint32) (*Index, error) {
+	for _, index := range space.indexes {
+		if index.Id == id {
+			return index, nil
+		}
+	}
+
+	return nil, tarantool.Error{
+		Code: tarantool.ErrNoSuchIndex,
+		Msg:  fmt.Sprintf("No index #%d is defined in space '%s'", id, space.Name),
+	}
+}
+
+// check validates tuple against space format and indexes.
+func (space *Space) check(t *tuple) error {
+	if space.opts.FieldCount > 0 && uint32(len(t.fields)) != space.opts.FieldCount {
+		return tarantool.Error{
+			Code: tarantool.ErrSpaceFieldCount,
+			Msg: fmt.Sprintf("Tuple field count %d does not match space '%s' field count %d",
+				len(t.fields), space.Name, space.opts.FieldCount),
+		}
+	}
+
+	for i, field := range space.opts.Format {
+		if i < len(t.fields) && !typeMatches(field.Type, value(t.fields[i])) {
+			return fieldTypeError(uint32(i), field.Type)
+		}
+	}
+
+	for _, index := range space.indexes {
+		if err := index.check(t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// put inserts tuple into all indexes. If replace is set, tuple of the same
+// primary key is replaced and returned, otherwise duplicate is an error.
+// It is called with server lock held.
+func (space *Space) put(t *tuple, replace bool) (*tuple, error) {
+	primary, err := space.index(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = space.check(t); err != nil {
+		return nil, err
+	}
+
+	var old *tuple
+
+	if replace {
+		if i, ok := primary.find(primary.key(t)); ok {
+			old = primary.tuples[i]
+		}
+	}
+
+	for _, index := range space.indexes {
+		if !index.opts.Unique {
+			continue
+		}
+
+		if i, ok := index.find(index.key(t)); ok && index.tuples[i] != old {
+			return nil, index.duplicate()
+		}
+	}
+
+	if old != nil {
+		space.remove(old)
+	}
+
+	for _, index := range space.indexes {
+		index.insert(t)
+	}
+
+	return old, nil
+}
+
+func (space *Space) remove(t *tuple) {
+	for _, index := range space.indexes {
+		index.remove(t)
+	}
+}
+
+// key returns indexed fields of tuple.
+func (index *Index) key(t *tuple) []msgp.Raw {
+	key := make([]msgp.Raw, len(index.opts.Parts))
+
+	for i, part := range index.opts.Parts {
+		if int(part.Field) < len(t.fields) {
+			key[i] = t.fields[part.Field]
+		}
+	}
+
+	return key
+}
+
+// position returns key which orders tuples of index: indexed fields
+// followed by fields of primary key for non unique index.
+func (index *Index) position(t *tuple) []msgp.Raw {
+	pos := index.key(t)
+
+	if !index.opts.Unique {
+		pos = append(pos, index.space.indexes[0].key(t)...)
+	}
+
+	return pos
+}
+
+func (index *Index) check(t *tuple) error {
+	for _, part := range index.opts.Parts {
+		if int(part.Field) >= len(t.fields) {
+			return tarantool.Error{
+				Code: tarantool.ErrIndexFieldCount,
+				Msg: fmt.Sprintf("Tuple field count %d is less than required by a defined index (expected %d)",
+					len(t.fields), part.Field+1),
+			}
+		}
+
+		if !typeMatches(part.Type, value(t.fields[part.Field])) {
+			return fieldTypeError(part.Field, part.Type)
+		}
+	}
+
+	return nil
+}
+
+func (index *Index) checkKey(key []msgp.Raw) error {
+	if len(key) > len(index.opts.Parts) {
+		return tarantool.Error{
+			Code: tarantool.ErrKeyPartCount,
+			Msg:  fmt.Sprintf("Invalid key part count (expected [0..%d], got %d)", len(index.opts.Parts), len(key)),
+		}
+	}
+
+	for i, part := range key {
+		if typ := index.opts.Parts[i].Type; !typeMatches(typ, value(part)) {
+			return tarantool.Error{
+				Code: tarantool.ErrKeyPartType,
+				Msg:  fmt.Sprintf("Supplied key type of part %d does not match index part type: expected %s", i, typ),
+			}
+		}
+	}
+
+	return nil
+}
+
+func (index *Index) checkExact(key []msgp.Raw) error {
+	if err := index.checkKey(key); err != nil {
+		return err
+	}
+
+	if len(key) != len(index.opts.Parts) {
+		return tarantool.Error{
+			Code: tarantool.ErrExactMatch,
+			Msg:  fmt.Sprintf("Invalid key part count in an exact match (expected %d, got %d)", len(index.opts.Parts), len(key)),
+		}
+	}
+
+	return nil
+}
+
+func (index *Index) duplicate() error {
+	return tarantool.Error{
+		Code: tarantool.ErrTupleFound,
+		Msg:  fmt.Sprintf("Duplicate key exists in unique index '%s' in space '%s'", index.Name, index.space.Name),
+	}
+}
+
+// find returns position of the first tuple with the key prefix and true if it matches.
+func (index *Index) find(key []msgp.Raw) (int, bool) {
+	i := sort.Search(len(index.tuples), func(i int) bool {
+		return compareKeys(index.key(index.tuples[i]), key) >= 0
+	})
+
+	return i, i < len(index.tuples) && compareKeys(index.key(index.tuples[i]), key) == 0
+}
+
+func (index *Index) search(t *tuple) int {
+	pos := index.position(t)
+
+	return sort.Search(len(index.tuples), func(i int) bool {
+		return compareKeys(index.position(index.tuples[i]), pos) >= 0
+	})
+}
+
+func (index *Index) insert(t *tuple) {
+	i := index.search(t)
+
+	index.tuples = append(index.tuples, nil)
+	copy(index.tuples[i+1:], index.tuples[i:])
+	index.tuples[i] = t
+}
+
+func (index *Index) remove(t *tuple) {
+	for i := index.search(t); i < len(index.tuples); i++ {
+		if index.tuples[i] == t {
+			index.tuples = append(index.tuples[:i], index.tuples[i+1:]...)
+
+			return
+		}
+	}
+}
+
+// get returns tuple of unique index by full key.
+func (index *Index) get(key []msgp.Raw) (*tuple, error) {
+	if !index.opts.Unique {
+		return nil, tarantool.Error{
+			Code: tarantool.ErrMoreThanOneTuple,
+			Msg:  fmt.Sprintf("Index '%s' of space '%s' is not unique", index.Name, index.space.Name),
+		}
+	}
+
+	if err := index.checkExact(key); err != nil {
+		return nil, err
+	}
+
+	if i, ok := index.find(key); ok {
+		return index.tuples[i], nil
+	}
+
+	return nil, nil
+}
+
+// selectTuples returns tuples matching key and iterator, which are after pos if it is not nil.
+func (index *Index) selectTuples(iterator uint32, key, after []msgp.Raw) ([]*tuple, error) {
+	if err := index.checkKey(key); err != nil {
+		return nil, err
+	}
+
+	if index.opts.Type == "hash" {
+		switch iterator {
+		case tarantool.IterAll:
+		case tarantool.IterEq, tarantool.IterGt:
+			if len(key) != 0 && len(key) != len(index.opts.Parts) {
+				return nil, index.checkExact(key)
+			}
+		default:
+			return nil, index.unsupported()
+		}
+	}
+
+	if len(key) == 0 {
+		// every tuple matches empty key
+		switch iterator {
+		case tarantool.IterGt:
+			iterator = tarantool.IterGe
+		case tarantool.IterLt:
+			iterator = tarantool.IterLe
+		}
+	}
+
+	var (
+		match func(c int) bool
+		desc  bool
+	)
+
+	switch iterator {
+	case tarantool.IterEq:
+		match = func(c int) bool { return c == 0 }
+	case tarantool.IterReq:
+		match, desc = func(c int) bool { return c == 0 }, true
+	case tarantool.IterAll:
+		match = func(c int) bool { return true }
+	case tarantool.IterGe:
+		match = func(c int) bool { return c >= 0 }
+	case tarantool.IterGt:
+		match = func(c int) bool { return c > 0 }
+	case tarantool.IterLe:
+		match, desc = func(c int) bool { return c <= 0 }, true
+	case tarantool.IterLt:
+		match, desc = func(c int) bool { return c < 0 }, true
+	default:
+		return nil, index.unsupported()
+	}
+
+	var tuples []*tuple
+
+	for i := range index.tuples {
+		t := index.tuples[i]
+		if desc {
+			t = index.tuples[len(index.tuples)-1-i]
+		}
+
+		if !match(compareKeys(index.key(t)[:len(key)], key)) {
+			continue
+		}
+
+		if after != nil {
+			c := compareKeys(index.position(t), after)
+			if !desc && c <= 0 || desc && c >= 0 {
+				continue
+			}
+		}
+
+		tuples = append(tuples, t)
+	}
+
+	return tuples, nil
+}
+
+func (index *Index) unsupported() error {
+	return tarantool.Error{
+		Code: tarantool.ErrUnsupported,
+		Msg:  fmt.Sprintf("Index '%s' (%s) of space '%s' does not support requested iterator type", index.Name, index.opts.Type, index.space.Name),
+	}
+}
+
+func fieldTypeError(field uint32, typ string) error {
+	return tarantool.Error{
+		Code: tarantool.ErrFieldType,
+		Msg:  fmt.Sprintf("Tuple field %d type does not match one required by operation: expected %s", field+1, typ),
+	}
+}
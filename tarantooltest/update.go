@@ -0,0 +1,334 @@
+package tarantooltest
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/GoWebProd/msgp/msgp"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+var (
+	minInt64  = big.NewInt(math.MinInt64)
+	maxUint64 = new(big.Int).SetUint64(math.MaxUint64)
+)
+
+// update returns copy of tuple with operations applied. Field numbers of
+// operations start with base. If skip is set, failed operations are skipped.
+// Primary key can't be changed. It is called with server lock held.
+func (space *Space) update(old *tuple, ops []msgp.Raw, base int64, skip bool) (*tuple, error) {
+	t := &tuple{fields: append([]msgp.Raw(nil), old.fields...)}
+
+	for i, raw := range ops {
+		op, err := parseTuple(raw)
+		if err == nil {
+			err = space.apply(t, op.fields, base)
+		} else {
+			err = unknownOp(i, "operation should be an array")
+		}
+
+		if err != nil && !skip {
+			return nil, err
+		}
+	}
+
+	primary := space.indexes[0]
+
+	if compareKeys(primary.key(old), primary.key(t)) != 0 {
+		return nil, tarantool.Error{
+			Code: tarantool.ErrCantUpdatePrimaryKey,
+			Msg:  fmt.Sprintf("Attempt to modify a tuple field which is part of index '%s' in space '%s'", primary.Name, space.Name),
+		}
+	}
+
+	return t, nil
+}
+
+func unknownOp(i int, reason string) error {
+	return tarantool.Error{
+		Code: tarantool.ErrUnknownUpdateOp,
+		Msg:  fmt.Sprintf("Unknown UPDATE operation #%d: %s", i+1, reason),
+	}
+}
+
+func noSuchField(field interface{}) error {
+	return tarantool.Error{
+		Code: tarantool.ErrNoSuchField,
+		Msg:  fmt.Sprintf("Field %v was not found in the tuple", field),
+	}
+}
+
+func argTypeError(op string, field interface{}, expected string) error {
+	return tarantool.Error{
+		Code: tarantool.ErrArgType,
+		Msg:  fmt.Sprintf("Argument type in operation '%s' on field %v does not match field type: expected %s", op, field, expected),
+	}
+}
+
+// field returns position of field of operation: field number or name of format.
+// Negative numbers are counted from the end, -1 is the last field for all
+// operations but insert, for which it is position after the last field.
+func (space *Space) field(raw msgp.Raw, t *tuple, base int64, op string) (int, interface{}, error) {
+	var no int64
+
+	switch v := value(raw).(type) {
+	case int64:
+		no = v
+	case uint64:
+		if v > math.MaxInt32 {
+			return 0, v, noSuchField(v)
+		}
+
+		no = int64(v)
+	case string:
+		for i, field := range space.opts.Format {
+			if field.Name == v {
+				return i, v, nil
+			}
+		}
+
+		return 0, v, noSuchField(fmt.Sprintf("'%s'", v))
+	default:
+		return 0, v, protocolError("field should be a number or a string")
+	}
+
+	n := int64(len(t.fields))
+
+	switch {
+	case no < 0 && op == "!":
+		no += n + 1
+	case no < 0:
+		no += n
+	default:
+		no -= base
+	}
+
+	// fields could be set and inserted right after the last one
+	if no < 0 || no > n || no == n && op != "=" && op != "!" {
+		return 0, no, noSuchField(no + base)
+	}
+
+	return int(no), no + base, nil
+}
+
+func (space *Space) apply(t *tuple, op []msgp.Raw, base int64) error {
+	if len(op) < 3 {
+		return unknownOp(0, "wrong number of arguments")
+	}
+
+	name, _, err := msgp.ReadStringBytes(op[0])
+	if err != nil {
+		return unknownOp(0, "operation name should be a string")
+	}
+
+	args := 3
+	if name == ":" {
+		args = 5
+	}
+
+	if len(op) != args {
+		return unknownOp(0, fmt.Sprintf("wrong number of arguments, expected %d, got %d", args, len(op)))
+	}
+
+	i, field, err := space.field(op[1], t, base, name)
+	if err != nil {
+		return err
+	}
+
+	switch name {
+	case "=":
+		if i == len(t.fields) {
+			t.fields = append(t.fields, op[2])
+		} else {
+			t.fields[i] = op[2]
+		}
+	case "!":
+		t.fields = append(t.fields, nil)
+		copy(t.fields[i+1:], t.fields[i:])
+		t.fields[i] = op[2]
+	case "#":
+		count, _, err := msgp.ReadUint64Bytes(op[2])
+		if err != nil || count == 0 {
+			return tarantool.Error{
+				Code: tarantool.ErrUpdateField,
+				Msg:  fmt.Sprintf("Field %v UPDATE error: cannot delete %v fields", field, value(op[2])),
+			}
+		}
+
+		if count > uint64(len(t.fields)-i) {
+			count = uint64(len(t.fields) - i)
+		}
+
+		t.fields = append(t.fields[:i], t.fields[i+int(count):]...)
+	case "+", "-":
+		res, err := arith(name, t.fields[i], op[2])
+		if err != nil {
+			return argTypeError(name, field, err.Error())
+		}
+
+		t.fields[i] = res
+	case "&", "|", "^":
+		a, okA := unsigned(value(t.fields[i]))
+		b, okB := unsigned(value(op[2]))
+
+		if !okA || !okB {
+			return argTypeError(name, field, "a positive integer")
+		}
+
+		switch name {
+		case "&":
+			a &= b
+		case "|":
+			a |= b
+		default:
+			a ^= b
+		}
+
+		t.fields[i] = msgp.AppendUint64(nil, a)
+	case ":":
+		res, err := splice(t.fields[i], op[2], op[3], op[4], base)
+		if err != nil {
+			return argTypeError(name, field, err.Error())
+		}
+
+		t.fields[i] = res
+	default:
+		return unknownOp(0, fmt.Sprintf("unknown operation '%s'", name))
+	}
+
+	return nil
+}
+
+func unsigned(v interface{}) (uint64, bool) {
+	switch v := v.(type) {
+	case uint64:
+		return v, true
+	case int64:
+		return uint64(v), v >= 0
+	}
+
+	return 0, false
+}
+
+type expectation string
+
+func (e expectation) Error() string {
+	return string(e)
+}
+
+// arith adds or subtracts numbers, integers are checked for overflow.
+func arith(op string, a, b msgp.Raw) (msgp.Raw, error) {
+	va, vb := value(a), value(b)
+
+	ia, okA := integer(va)
+	ib, okB := integer(vb)
+
+	if okA && okB {
+		if op == "+" {
+			ia.Add(ia, ib)
+		} else {
+			ia.Sub(ia, ib)
+		}
+
+		if ia.Cmp(minInt64) < 0 || ia.Cmp(maxUint64) > 0 {
+			return nil, expectation("a number without integer overflow")
+		}
+
+		if ia.Sign() < 0 {
+			return msgp.AppendInt64(nil, ia.Int64()), nil
+		}
+
+		return msgp.AppendUint64(nil, ia.Uint64()), nil
+	}
+
+	fa, okA := float(va)
+	fb, okB := float(vb)
+
+	if !okA || !okB {
+		return nil, expectation("a number")
+	}
+
+	if op == "-" {
+		fb = -fb
+	}
+
+	if _, ok := va.(float32); ok {
+		if _, ok = vb.(float32); ok {
+			return msgp.AppendFloat32(nil, float32(fa+fb)), nil
+		}
+	}
+
+	return msgp.AppendFloat64(nil, fa+fb), nil
+}
+
+func integer(v interface{}) (*big.Int, bool) {
+	switch v := v.(type) {
+	case int64:
+		return big.NewInt(v), true
+	case uint64:
+		return new(big.Int).SetUint64(v), true
+	}
+
+	return nil, false
+}
+
+func float(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+
+	return 0, false
+}
+
+// splice replaces length bytes of string at position with replacement.
+// Negative position is counted from the end, -1 is position after the last byte.
+func splice(field, position, length, replacement msgp.Raw, base int64) (msgp.Raw, error) {
+	str, ok := value(field).(string)
+	if !ok {
+		return nil, expectation("a string")
+	}
+
+	pos, okPos := value(position).(int64)
+	if u, ok := value(position).(uint64); ok {
+		pos, okPos = int64(u), u <= math.MaxInt32
+	}
+
+	cut, okCut := unsigned(value(length))
+	repl, okRepl := value(replacement).(string)
+
+	if !okPos || !okCut || !okRepl {
+		return nil, expectation("a position, a length and a string")
+	}
+
+	n := int64(len(str))
+
+	if pos < 0 {
+		pos += n + 1
+		if pos < 0 {
+			return nil, expectation("a position inside of the string")
+		}
+	} else {
+		pos -= base
+		if pos < 0 {
+			pos = 0
+		}
+	}
+
+	if pos > n {
+		pos = n
+	}
+
+	if cut > uint64(n-pos) {
+		cut = uint64(n - pos)
+	}
+
+	return msgp.AppendString(nil, str[:pos]+repl+str[pos+int64(cut):]), nil
+}
@@ -0,0 +1,230 @@
+package tarantooltest
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/GoWebProd/msgp/msgp"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+// value decodes field, fields which can't be decoded are returned raw.
+func value(field msgp.Raw) interface{} {
+	if field == nil {
+		return nil
+	}
+
+	v, _, err := tarantool.Decode(field)
+	if err != nil {
+		return field
+	}
+
+	return v
+}
+
+// typeMatches returns true if value could be stored in field of typ.
+func typeMatches(typ string, v interface{}) bool {
+	switch typ {
+	case "unsigned", "uint", "num":
+		switch v := v.(type) {
+		case uint64:
+			return true
+		case int64:
+			return v >= 0
+		}
+
+		return false
+	case "integer", "int":
+		switch v.(type) {
+		case int64, uint64:
+			return true
+		}
+
+		return false
+	case "number", "double":
+		return isNumber(v)
+	case "string", "str":
+		_, ok := v.(string)
+
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+
+		return ok
+	case "varbinary":
+		_, ok := v.([]byte)
+
+		return ok
+	case "uuid":
+		_, ok := v.(*tarantool.UUID)
+
+		return ok
+	case "decimal":
+		_, ok := v.(*tarantool.Decimal)
+
+		return ok
+	case "datetime":
+		_, ok := v.(*tarantool.Datetime)
+
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+
+		return ok
+	case "map":
+		_, ok := v.(map[interface{}]interface{})
+
+		return ok
+	case "scalar":
+		switch v.(type) {
+		case nil, []interface{}, map[interface{}]interface{}:
+			return false
+		}
+
+		return true
+	default:
+		// any
+		return true
+	}
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int64, uint64, float32, float64, *tarantool.Decimal:
+		return true
+	}
+
+	return false
+}
+
+// class orders values of different types as tarantool scalar does.
+func class(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case int64, uint64, float32, float64, *tarantool.Decimal:
+		return 2
+	case string:
+		return 3
+	case []byte:
+		return 4
+	case *tarantool.UUID:
+		return 5
+	case *tarantool.Datetime:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// number converts numeric value to big.Float, NaN is returned as nil.
+func number(v interface{}) *big.Float {
+	switch v := v.(type) {
+	case int64:
+		return new(big.Float).SetInt64(v)
+	case uint64:
+		return new(big.Float).SetUint64(v)
+	case float32:
+		if math.IsNaN(float64(v)) {
+			return nil
+		}
+
+		return big.NewFloat(float64(v))
+	case float64:
+		if math.IsNaN(v) {
+			return nil
+		}
+
+		return big.NewFloat(v)
+	case *tarantool.Decimal:
+		f, _, err := big.ParseFloat(v.String(), 10, 256, big.ToNearestEven)
+		if err != nil {
+			return nil
+		}
+
+		return f
+	}
+
+	return nil
+}
+
+// compare compares encoded values.
+func compare(a, b msgp.Raw) int {
+	va, vb := value(a), value(b)
+
+	if ca, cb := class(va), class(vb); ca != cb {
+		if ca < cb {
+			return -1
+		}
+
+		return 1
+	}
+
+	switch va := va.(type) {
+	case bool:
+		switch vb := vb.(bool); {
+		case va == vb:
+			return 0
+		case vb:
+			return -1
+		default:
+			return 1
+		}
+	case string:
+		return strings.Compare(va, vb.(string))
+	case []byte:
+		return bytes.Compare(va, vb.([]byte))
+	case *tarantool.UUID:
+		return bytes.Compare(va[:], vb.(*tarantool.UUID)[:])
+	case *tarantool.Datetime:
+		switch tb := vb.(*tarantool.Datetime).Time; {
+		case va.Time.Before(tb):
+			return -1
+		case va.Time.After(tb):
+			return 1
+		default:
+			return 0
+		}
+	case nil:
+		return 0
+	}
+
+	if isNumber(va) {
+		na, nb := number(va), number(vb)
+
+		switch {
+		case na == nil && nb == nil:
+			return 0
+		case na == nil:
+			return -1
+		case nb == nil:
+			return 1
+		default:
+			return na.Cmp(nb)
+		}
+	}
+
+	return bytes.Compare(a, b)
+}
+
+// compareKeys compares keys part by part, shorter key is less.
+func compareKeys(a, b []msgp.Raw) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	default:
+		return 0
+	}
+}
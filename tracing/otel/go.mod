@@ -0,0 +1,22 @@
+module gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/tracing/otel
+
+go 1.18
+
+require (
+	gitlab.corp.mail.ru/icqweb/go/go-tarantool.git v0.0.0
+	go.opentelemetry.io/otel v1.14.0
+	go.opentelemetry.io/otel/sdk v1.14.0
+	go.opentelemetry.io/otel/trace v1.14.0
+)
+
+require (
+	github.com/GoWebProd/gip v0.0.0-20211004204909-3ddd41d029c0 // indirect
+	github.com/GoWebProd/msgp v1.2.4 // indirect
+	github.com/go-logr/logr v1.2.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/philhofer/fwd v1.1.2-0.20210722190033-5c56ac6d0bb9 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	golang.org/x/sys v0.5.0 // indirect
+)
+
+replace gitlab.corp.mail.ru/icqweb/go/go-tarantool.git => ../..
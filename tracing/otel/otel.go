@@ -0,0 +1,76 @@
+// Package otel provides tracing interceptor which creates OpenTelemetry
+// spans. It is separate module, so the client itself doesn't depend on
+// OpenTelemetry:
+//
+//	opts.Interceptors = append(opts.Interceptors, otel.Interceptor(otel.Opts{}))
+//
+// Spans are created by tracer of global provider by default.
+package otel
+
+import (
+	"context"
+
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+	"gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is name of tracer.
+const InstrumentationName = "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/tracing/otel"
+
+// Opts are options of interceptor.
+type Opts struct {
+	// TracerProvider provides tracer, global provider is used if it is nil.
+	TracerProvider trace.TracerProvider
+}
+
+// Interceptor returns interceptor which wraps every request into
+// OpenTelemetry span of client kind, see tracing.Interceptor.
+func Interceptor(opts Opts) tarantool.Interceptor {
+	return tracing.Interceptor(NewTracer(opts))
+}
+
+// NewTracer returns tracing.Tracer which starts OpenTelemetry spans.
+func NewTracer(opts Opts) tracing.Tracer {
+	provider := opts.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+
+	return tracer{provider.Tracer(InstrumentationName)}
+}
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+func (t tracer) Start(ctx context.Context, name string) (context.Context, tracing.Span) {
+	ctx, s := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+
+	return ctx, span{s}
+}
+
+type span struct {
+	span trace.Span
+}
+
+func (s span) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		s.span.SetAttributes(attribute.String(key, v))
+	case int64:
+		s.span.SetAttributes(attribute.Int64(key, v))
+	}
+}
+
+func (s span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s span) End() {
+	s.span.End()
+}
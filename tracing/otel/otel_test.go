@@ -0,0 +1,62 @@
+package otel
+
+import (
+	"testing"
+	"time"
+
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+	"gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInterceptor(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	// nobody listens there, connection will be reconnecting in background
+	conn, err := tarantool.Connect("127.0.0.1:1", tarantool.Opts{
+		Reconnect:    time.Hour,
+		SkipSchema:   true,
+		Interceptors: []tarantool.Interceptor{Interceptor(Opts{TracerProvider: provider})},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create connection: %s", err.Error())
+	}
+	defer conn.Close()
+
+	if _, err = conn.Call17("simple_incr", nil); err == nil {
+		t.Fatalf("Call17 of not connected connection should fail")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+
+	s := spans[0]
+
+	if s.Name() != "call17 simple_incr" || s.SpanKind() != trace.SpanKindClient || s.Status().Code != codes.Error {
+		t.Fatalf("unexpected span %s of kind %s with status %v", s.Name(), s.SpanKind(), s.Status())
+	}
+
+	attrs := make(map[attribute.Key]attribute.Value)
+
+	for _, attr := range s.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+
+	for key, value := range map[string]attribute.Value{
+		tracing.AttrSystem:    attribute.StringValue("tarantool"),
+		tracing.AttrOperation: attribute.StringValue("call17"),
+		tracing.AttrFunction:  attribute.StringValue("simple_incr"),
+		tracing.AttrErrorCode: attribute.Int64Value(int64(tarantool.ErrConnectionNotReady)),
+	} {
+		if attrs[attribute.Key(key)] != value {
+			t.Fatalf("attribute %s is %v, expected %v", key, attrs[attribute.Key(key)].Emit(), value.Emit())
+		}
+	}
+}
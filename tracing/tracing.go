@@ -0,0 +1,100 @@
+// Package tracing provides interceptor which creates a span for every
+// request performed by tarantool.Connection.
+//
+// It depends only on minimal Tracer and Span interfaces, so the client
+// does not pull tracing SDK. OpenTelemetry interceptor is provided by
+// separate module gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/tracing/otel:
+//
+//	opts.Interceptors = append(opts.Interceptors, otel.Interceptor(otel.Opts{}))
+package tracing
+
+import (
+	"context"
+
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+// Attribute keys set on spans.
+const (
+	AttrSystem    = "db.system"
+	AttrOperation = "db.operation"
+	AttrSpace     = "db.tarantool.space"
+	AttrFunction  = "db.tarantool.function"
+	AttrRequestId = "db.tarantool.request_id"
+	AttrErrorCode = "db.tarantool.error_code"
+)
+
+// Tracer starts spans.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a started span. Values of attributes are strings or int64.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// Interceptor returns interceptor which wraps every request into a span.
+// Span is named after operation and space or function, ie "select users".
+// Expression of eval is recorded as function attribute.
+func Interceptor(tracer Tracer) tarantool.Interceptor {
+	return func(ctx context.Context, req tarantool.Request, next tarantool.Invoker) (tarantool.Response, error) {
+		name := req.Name()
+		space := req.SpaceName()
+
+		var function string
+
+		switch req.Code() {
+		case tarantool.CallRequest, tarantool.Call17Request, tarantool.EvalRequest:
+			function = req.Function()
+		}
+
+		switch {
+		case space != "":
+			name += " " + space
+		case function != "" && req.Code() != tarantool.EvalRequest:
+			// expression of eval could be long, so it is not in span name
+			name += " " + function
+		}
+
+		ctx, span := tracer.Start(ctx, name)
+		defer span.End()
+
+		span.SetAttribute(AttrSystem, "tarantool")
+		span.SetAttribute(AttrOperation, req.Name())
+
+		if space != "" {
+			span.SetAttribute(AttrSpace, space)
+		}
+
+		if function != "" {
+			span.SetAttribute(AttrFunction, function)
+		}
+
+		resp, err := next(ctx, req)
+
+		if resp.RequestId != 0 {
+			span.SetAttribute(AttrRequestId, int64(resp.RequestId))
+		}
+
+		switch e := err.(type) {
+		case nil:
+			if resp.Code != tarantool.OkCode {
+				span.SetAttribute(AttrErrorCode, int64(resp.Code))
+				span.RecordError(tarantool.Error{Code: resp.Code, Msg: resp.Error})
+			}
+		case tarantool.ClientError:
+			span.SetAttribute(AttrErrorCode, int64(e.Code))
+			span.RecordError(err)
+		case tarantool.Error:
+			span.SetAttribute(AttrErrorCode, int64(e.Code))
+			span.RecordError(err)
+		default:
+			span.RecordError(err)
+		}
+
+		return resp, err
+	}
+}
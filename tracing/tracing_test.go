@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+type span struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *span) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *span) RecordError(err error)                      { s.err = err }
+func (s *span) End()                                       { s.ended = true }
+
+type tracer struct {
+	spans []*span
+}
+
+func (t *tracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	s := &span{name: name, attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, s)
+
+	return ctx, s
+}
+
+func TestInterceptor(t *testing.T) {
+	tr := &tracer{}
+
+	// nobody listens there, connection will be reconnecting in background
+	conn, err := tarantool.Connect("127.0.0.1:1", tarantool.Opts{
+		Reconnect:    time.Hour,
+		SkipSchema:   true,
+		Interceptors: []tarantool.Interceptor{Interceptor(tr)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create connection: %s", err.Error())
+	}
+	defer conn.Close()
+
+	if _, err = conn.Call17("simple_incr", nil); err == nil {
+		t.Fatalf("Call17 of not connected connection should fail")
+	}
+
+	if len(tr.spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(tr.spans))
+	}
+
+	s := tr.spans[0]
+
+	if s.name != "call17 simple_incr" || !s.ended || s.err != err {
+		t.Fatalf("unexpected span: %+v", s)
+	}
+
+	for key, value := range map[string]interface{}{
+		AttrSystem:    "tarantool",
+		AttrOperation: "call17",
+		AttrFunction:  "simple_incr",
+		AttrErrorCode: int64(tarantool.ErrConnectionNotReady),
+	} {
+		if s.attrs[key] != value {
+			t.Fatalf("attribute %s is %v, expected %v", key, s.attrs[key], value)
+		}
+	}
+}
+
+func TestInterceptorEval(t *testing.T) {
+	tr := &tracer{}
+
+	conn, err := tarantool.Connect("127.0.0.1:1", tarantool.Opts{
+		Reconnect:    time.Hour,
+		SkipSchema:   true,
+		Interceptors: []tarantool.Interceptor{Interceptor(tr)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create connection: %s", err.Error())
+	}
+	defer conn.Close()
+
+	conn.Eval("return box.info.version", nil)
+
+	if len(tr.spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(tr.spans))
+	}
+
+	if s := tr.spans[0]; s.name != "eval" || s.attrs[AttrFunction] != "return box.info.version" {
+		t.Fatalf("unexpected span: %+v", s)
+	}
+}
@@ -0,0 +1,217 @@
+package tarantool
+
+import (
+	"context"
+	"strings"
+
+	"github.com/GoWebProd/msgp/msgp"
+)
+
+// TupleIterator iterates over tuples of response without decoding them.
+type TupleIterator struct {
+	data  []byte
+	left  uint32
+	tuple []byte
+	err   error
+}
+
+// Tuples returns iterator over tuples of response data.
+// Tuples are raw msgpack which refers to response buffer,
+// so they are valid until response is released.
+func (resp *Response) Tuples() TupleIterator {
+	var it TupleIterator
+
+	if len(resp.Data) == 0 {
+		return it
+	}
+
+	it.left, it.data, it.err = msgp.ReadArrayHeaderBytes(resp.Data)
+
+	return it
+}
+
+// Next advances iterator to the next tuple.
+// It returns false when there are no more tuples or data is malformed.
+func (it *TupleIterator) Next() bool {
+	it.tuple = nil
+
+	if it.err != nil || it.left == 0 {
+		return false
+	}
+
+	remain, err := msgp.Skip(it.data)
+	if err != nil {
+		it.err = err
+
+		return false
+	}
+
+	it.tuple = it.data[:len(it.data)-len(remain)]
+	it.data = remain
+	it.left--
+
+	return true
+}
+
+// Tuple returns raw msgpack of the current tuple.
+func (it *TupleIterator) Tuple() []byte {
+	return it.tuple
+}
+
+// Decode decodes the current tuple into v, so one value could be reused.
+func (it *TupleIterator) Decode(v msgp.Unmarshaler) error {
+	_, err := v.UnmarshalMsg(it.tuple)
+
+	return err
+}
+
+// Len returns number of tuples which are not iterated yet.
+func (it *TupleIterator) Len() int {
+	return int(it.left)
+}
+
+// Err returns error of decoding response data.
+func (it *TupleIterator) Err() error {
+	return it.err
+}
+
+// SelectAll selects tuples of index matching key and iterator by batches
+// of limit tuples and calls f for every one of them, until f returns error.
+// Tuple passed to f is valid only during the call.
+//
+// When schema is loaded and index is unique tree, next batch is selected
+// after the last tuple of previous one with IterGt or IterLt, so iterators
+// IterAll, IterGe, IterGt, IterLe and IterLt walk index in O(n). Otherwise
+// batches are selected with offset.
+func (conn *Connection) SelectAll(ctx context.Context, space, index, iterator uint32, key Body, limit uint32, f func(tuple []byte) error) error {
+	var (
+		offset uint32
+		parts  []*IndexField
+	)
+
+	if limit == 0 {
+		limit = 1000
+	}
+
+	next, ok := pagingIterator(iterator)
+	if ok {
+		parts = conn.uniqueTreeParts(space, index)
+	}
+
+	for {
+		resp, err := conn.Do(ctx, NewSelectRequest(space, index, offset, limit, iterator, key))
+		if err != nil {
+			return err
+		}
+
+		if resp.Code != OkCode {
+			resp.Release()
+
+			return Error{resp.Code, resp.Error}
+		}
+
+		var last []byte
+
+		it := resp.Tuples()
+		n := it.Len()
+
+		for it.Next() {
+			last = it.Tuple()
+
+			if err = f(last); err != nil {
+				resp.Release()
+
+				return err
+			}
+		}
+
+		if err = it.Err(); err != nil {
+			resp.Release()
+
+			return err
+		}
+
+		if n < int(limit) {
+			resp.Release()
+
+			return nil
+		}
+
+		if parts == nil {
+			offset += limit
+		} else {
+			if key, err = tupleKey(last, parts); err != nil {
+				resp.Release()
+
+				return err
+			}
+
+			iterator = next
+		}
+
+		resp.Release()
+	}
+}
+
+// pagingIterator returns iterator which selects tuples after the last one.
+func pagingIterator(iterator uint32) (uint32, bool) {
+	switch iterator {
+	case IterAll, IterGe, IterGt:
+		return IterGt, true
+	case IterLe, IterLt:
+		return IterLt, true
+	default:
+		return iterator, false
+	}
+}
+
+func (conn *Connection) uniqueTreeParts(space, index uint32) []*IndexField {
+	schema := conn.GetSchema()
+	if schema == nil {
+		return nil
+	}
+
+	sp, ok := schema.SpacesById[space]
+	if !ok {
+		return nil
+	}
+
+	idx, ok := sp.IndexesById[index]
+	if !ok || !idx.Unique || !strings.EqualFold(idx.Type, "tree") || len(idx.Fields) == 0 {
+		return nil
+	}
+
+	return idx.Fields
+}
+
+// tupleKey makes key of index from fields of tuple.
+func tupleKey(tuple []byte, parts []*IndexField) (msgp.Raw, error) {
+	n, remain, err := msgp.ReadArrayHeaderBytes(tuple)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([][]byte, n)
+
+	for i := range fields {
+		field := remain
+
+		if remain, err = msgp.Skip(remain); err != nil {
+			return nil, err
+		}
+
+		fields[i] = field[:len(field)-len(remain)]
+	}
+
+	key := msgp.AppendArrayHeader(nil, uint32(len(parts)))
+
+	for _, part := range parts {
+		if part.Id >= n {
+			return nil, ClientError{ErrProtocolError, "tuple has no field of index"}
+		}
+
+		key = append(key, fields[part.Id]...)
+	}
+
+	return msgp.Raw(key), nil
+}
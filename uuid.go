@@ -0,0 +1,162 @@
+package tarantool
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+)
+
+// UUIDExtension is msgpack extension type of tarantool uuid.
+const UUIDExtension = 2
+
+// UUID is tarantool uuid. It has the same layout as [16]byte based
+// uuid libraries, ie github.com/google/uuid, so they are converted
+// with tarantool.UUID(u) and uuid.UUID(tu).
+// It is decoded both from uuid extension and from string.
+type UUID [16]byte
+
+// NewUUID returns random uuid of version 4.
+func NewUUID() (UUID, error) {
+	var u UUID
+
+	if _, err := rand.Read(u[:]); err != nil {
+		return UUID{}, errors.Wrap(err, "can't generate uuid")
+	}
+
+	// RFC 4122 version 4
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+
+	return u, nil
+}
+
+// ParseUUID parses uuid in canonical form, ie "7170b4af-c72f-4f07-8729-08fc678543a1".
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, errors.Errorf("can't parse uuid %q", s)
+	}
+
+	if _, err := hex.Decode(u[:], []byte(strings.ReplaceAll(s, "-", ""))); err != nil {
+		return UUID{}, errors.Wrapf(err, "can't parse uuid %q", s)
+	}
+
+	return u, nil
+}
+
+// String returns uuid in canonical form.
+func (u UUID) String() string {
+	var b [36]byte
+
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+
+	return string(b[:])
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (u *UUID) UnmarshalText(text []byte) (err error) {
+	*u, err = ParseUUID(string(text))
+
+	return err
+}
+
+// ExtensionType implements msgp.Extension.
+func (u *UUID) ExtensionType() int8 {
+	return UUIDExtension
+}
+
+// Len implements msgp.Extension.
+func (u *UUID) Len() int {
+	return 16
+}
+
+// MarshalBinaryTo implements msgp.Extension.
+func (u *UUID) MarshalBinaryTo(b []byte) error {
+	copy(b, u[:])
+
+	return nil
+}
+
+// UnmarshalBinary implements msgp.Extension.
+func (u *UUID) UnmarshalBinary(b []byte) error {
+	if len(b) != 16 {
+		return errors.Errorf("can't decode uuid of %d bytes", len(b))
+	}
+
+	copy(u[:], b)
+
+	return nil
+}
+
+// EncodeMsg implements msgp.Encodable.
+func (u *UUID) EncodeMsg(w *msgp.Writer) error {
+	return w.WriteExtension(u)
+}
+
+// DecodeMsg implements msgp.Decodable.
+func (u *UUID) DecodeMsg(r *msgp.Reader) error {
+	t, err := r.NextType()
+	if err != nil {
+		return err
+	}
+
+	if t == msgp.StrType {
+		s, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+
+		*u, err = ParseUUID(s)
+
+		return err
+	}
+
+	return r.ReadExtension(u)
+}
+
+// MarshalMsg implements msgp.Marshaler.
+func (u *UUID) MarshalMsg(b []byte) ([]byte, error) {
+	return msgp.AppendExtension(b, u)
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler.
+func (u *UUID) UnmarshalMsg(b []byte) ([]byte, error) {
+	if msgp.NextType(b) == msgp.StrType {
+		s, remain, err := msgp.ReadStringZC(b)
+		if err != nil {
+			return b, err
+		}
+
+		*u, err = ParseUUID(string(s))
+
+		return remain, err
+	}
+
+	return msgp.ReadExtensionBytes(b, u)
+}
+
+// Msgsize implements msgp.Sizer.
+func (u *UUID) Msgsize() int {
+	return extensionSize(16)
+}
+
+func init() {
+	msgp.RegisterExtension(UUIDExtension, func() msgp.Extension { return new(UUID) })
+}
@@ -0,0 +1,106 @@
+package vshard
+
+import (
+	"hash/crc32"
+	"math"
+	"reflect"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// BucketID returns bucket id of key as vshard.router.bucket_id_mpcrc32 does:
+// crc32 of key encoded with msgpack, but strings are not encoded. Composite key
+// is passed as slice, its parts are hashed one by one.
+func BucketID(key interface{}, total uint64) (uint64, error) {
+	var (
+		b   []byte
+		err error
+	)
+
+	if v := reflect.ValueOf(key); v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < v.Len(); i++ {
+			if b, err = appendKey(b, v.Index(i).Interface()); err != nil {
+				return 0, err
+			}
+		}
+	} else if b, err = appendKey(b, key); err != nil {
+		return 0, err
+	}
+
+	return uint64(crc32c(b))%total + 1, nil
+}
+
+// crc32c returns checksum as digest.crc32 of tarantool calculates it:
+// initial value is 0xffffffff and there is no final inversion.
+func crc32c(b []byte) uint32 {
+	return ^crc32.Checksum(b, castagnoli)
+}
+
+// appendKey appends part of key as msgpack.encode of tarantool does:
+// numbers are encoded in the smallest form, non-negative ones as unsigned,
+// and integral floats as integers.
+func appendKey(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return append(b, v...), nil
+	case []byte:
+		return append(b, v...), nil
+	case int:
+		return appendInt(b, int64(v)), nil
+	case int8:
+		return appendInt(b, int64(v)), nil
+	case int16:
+		return appendInt(b, int64(v)), nil
+	case int32:
+		return appendInt(b, int64(v)), nil
+	case int64:
+		return appendInt(b, v), nil
+	case uint:
+		return msgp.AppendUint64(b, uint64(v)), nil
+	case uint8:
+		return msgp.AppendUint64(b, uint64(v)), nil
+	case uint16:
+		return msgp.AppendUint64(b, uint64(v)), nil
+	case uint32:
+		return msgp.AppendUint64(b, uint64(v)), nil
+	case uint64:
+		return msgp.AppendUint64(b, v), nil
+	case float32:
+		return appendFloat(b, float64(v)), nil
+	case float64:
+		return appendFloat(b, v), nil
+	case bool:
+		return msgp.AppendBool(b, v), nil
+	case nil:
+		return msgp.AppendNil(b), nil
+	case tarantool.UUID:
+		return msgp.AppendExtension(b, &v)
+	default:
+		return nil, errors.Errorf("vshard: unsupported key type %T", v)
+	}
+}
+
+func appendInt(b []byte, i int64) []byte {
+	if i >= 0 {
+		return msgp.AppendUint64(b, uint64(i))
+	}
+
+	return msgp.AppendInt64(b, i)
+}
+
+func appendFloat(b []byte, f float64) []byte {
+	if f == math.Trunc(f) {
+		switch {
+		case f >= 0 && f < math.Exp2(64):
+			return msgp.AppendUint64(b, uint64(f))
+		case f < 0 && f >= -math.Exp2(63):
+			return msgp.AppendInt64(b, int64(f))
+		}
+	}
+
+	return msgp.AppendFloat64(b, f)
+}
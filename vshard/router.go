@@ -0,0 +1,527 @@
+// Package vshard is router of tarantool/vshard cluster. It computes bucket
+// id of sharding key as vshard does, discovers which replicaset stores every
+// bucket and calls functions of storages through vshard.storage.call:
+//
+//	r, err := vshard.New(vshard.Opts{
+//		Replicasets: map[string]string{
+//			"cbf06940-0790-498b-948d-042b62cf3d29": "127.0.0.1:3301",
+//			"ac522f65-aa94-4134-9f64-51ee384f1a54": "127.0.0.1:3302",
+//		},
+//		DiscoveryInterval: 10 * time.Second,
+//	})
+//	defer r.Close()
+//
+//	bucketID, err := vshard.BucketID(userID, r.BucketCount())
+//	values, err := r.CallRW(ctx, bucketID, "put_user", []interface{}{userID, name})
+//
+// Buckets are moved by rebalancer while router works: calls of moved
+// buckets fail on storages with WRONG_BUCKET or TRANSFER_IS_IN_PROGRESS
+// errors, router updates routes and retries them.
+//
+// Router connects to masters only, so CallRO is executed on master as well.
+package vshard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+)
+
+// DefaultBucketCount is default number of buckets of vshard.
+const DefaultBucketCount = 3000
+
+// Codes of vshard errors.
+const (
+	ErrWrongBucket           = 1
+	ErrNonMaster             = 2
+	ErrBucketAlreadyExists   = 3
+	ErrNoSuchReplicaset      = 4
+	ErrMoveToSelf            = 5
+	ErrMissingMaster         = 6
+	ErrTransferIsInProgress  = 7
+	ErrUnreachableReplicaset = 8
+	ErrNoRouteToBucket       = 9
+	ErrNonEmpty              = 10
+	ErrUnreachableMaster     = 11
+	ErrBucketIsLocked        = 22
+)
+
+// Error is error returned by vshard.
+type Error struct {
+	// Type is type of error, ie "ShardingError", or "ClientError"
+	// for box errors.
+	Type    string
+	Code    uint64
+	Name    string
+	Message string
+	// BucketID is bucket of error, if it is known.
+	BucketID uint64
+	// Destination is uuid of replicaset which bucket is moved to,
+	// if it is known.
+	Destination string
+}
+
+func (e *Error) Error() string {
+	if e.Name == "" {
+		return "vshard: " + e.Message
+	}
+
+	return fmt.Sprintf("vshard: %s: %s", e.Name, e.Message)
+}
+
+// decodeError decodes error table returned by storage.
+func decodeError(v interface{}) *Error {
+	fields, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return &Error{Message: fmt.Sprint(v)}
+	}
+
+	e := &Error{}
+	e.Type, _ = fields["type"].(string)
+	e.Code, _ = toUint(fields["code"])
+	e.Name, _ = fields["name"].(string)
+	e.Message, _ = fields["message"].(string)
+	e.BucketID, _ = toUint(fields["bucket_id"])
+	e.Destination, _ = fields["destination"].(string)
+
+	return e
+}
+
+// Opts are options of router.
+type Opts struct {
+	// Replicasets maps uuid of replicaset to address of its master.
+	Replicasets map[string]string
+	// ConnOpts are options of connections to replicasets.
+	ConnOpts tarantool.Opts
+	// BucketCount is number of buckets, it must be the same as
+	// bucket_count of storages. Default is DefaultBucketCount.
+	BucketCount uint64
+	// DiscoveryInterval is interval of background discovery of buckets.
+	// Discovery is not started if it is zero: buckets are found on demand.
+	DiscoveryInterval time.Duration
+	// RetryInterval is delay of retry of bucket which is being transferred
+	// or is not found. Default is 50ms.
+	RetryInterval time.Duration
+	// MaxRetries limits retries of call, default is 10.
+	MaxRetries int
+	// Logger receives discovery errors and reroutes of buckets.
+	// ConnOpts.Logger is used by default.
+	Logger tarantool.Logger
+}
+
+// Router routes calls to replicasets by bucket id.
+type Router struct {
+	opts Opts
+
+	// uuids are sorted uuids of replicasets
+	uuids       []string
+	replicasets map[string]*tarantool.Connection
+
+	mutex sync.RWMutex
+	// routes are uuids of replicasets by bucket id, empty if unknown
+	routes []string
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	wg        sync.WaitGroup
+}
+
+// New connects to masters of replicasets and starts discovery.
+func New(opts Opts) (*Router, error) {
+	if len(opts.Replicasets) == 0 {
+		return nil, errors.New("vshard: no replicasets")
+	}
+
+	if opts.BucketCount == 0 {
+		opts.BucketCount = DefaultBucketCount
+	}
+
+	if opts.RetryInterval == 0 {
+		opts.RetryInterval = 50 * time.Millisecond
+	}
+
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 10
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = opts.ConnOpts.Logger
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = nopLogger{}
+	}
+
+	r := &Router{
+		opts:        opts,
+		replicasets: make(map[string]*tarantool.Connection, len(opts.Replicasets)),
+		routes:      make([]string, opts.BucketCount+1),
+		closed:      make(chan struct{}),
+	}
+
+	for uuid, addr := range opts.Replicasets {
+		conn, err := tarantool.Connect(addr, opts.ConnOpts)
+		if err != nil {
+			r.Close()
+
+			return nil, errors.Wrapf(err, "can't connect to replicaset %s", uuid)
+		}
+
+		r.uuids = append(r.uuids, uuid)
+		r.replicasets[uuid] = conn
+	}
+
+	sort.Strings(r.uuids)
+
+	if opts.DiscoveryInterval > 0 {
+		r.wg.Add(1)
+
+		go r.discovery()
+	}
+
+	return r, nil
+}
+
+// Close stops discovery and closes connections.
+func (r *Router) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.closed)
+	})
+
+	r.wg.Wait()
+
+	var err error
+
+	for _, conn := range r.replicasets {
+		if cerr := conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// BucketCount returns number of buckets.
+func (r *Router) BucketCount() uint64 {
+	return r.opts.BucketCount
+}
+
+// Replicaset returns connection to master of replicaset, ie to call
+// functions on every replicaset. It returns nil if replicaset is unknown.
+func (r *Router) Replicaset(uuid string) *tarantool.Connection {
+	return r.replicasets[uuid]
+}
+
+// discovery discovers buckets every DiscoveryInterval until Close.
+func (r *Router) discovery() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.opts.DiscoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), r.opts.DiscoveryInterval)
+
+		go func() {
+			select {
+			case <-r.closed:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		if err := r.Discover(ctx); err != nil {
+			r.opts.Logger.Warn("vshard: discovery failed", "error", err)
+		}
+
+		cancel()
+
+		select {
+		case <-r.closed:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Discover updates routes of all buckets. Replicasets which have failed
+// keep their routes, the first error is returned.
+func (r *Router) Discover(ctx context.Context) error {
+	var err error
+
+	for _, uuid := range r.uuids {
+		buckets, derr := r.discover(ctx, uuid)
+		if derr != nil {
+			if err == nil {
+				err = errors.Wrapf(derr, "can't discover buckets of replicaset %s", uuid)
+			}
+
+			continue
+		}
+
+		owned := make(map[uint64]struct{}, len(buckets))
+
+		r.mutex.Lock()
+
+		for _, id := range buckets {
+			if id > 0 && id <= r.opts.BucketCount {
+				owned[id] = struct{}{}
+				r.routes[id] = uuid
+			}
+		}
+
+		for id, route := range r.routes {
+			if _, ok := owned[uint64(id)]; route == uuid && !ok {
+				r.routes[id] = ""
+			}
+		}
+
+		r.mutex.Unlock()
+	}
+
+	return err
+}
+
+// discover returns active buckets of replicaset. New versions of vshard
+// return them by pages with the next bucket id to request, old ones
+// return all buckets at once.
+func (r *Router) discover(ctx context.Context, uuid string) ([]uint64, error) {
+	var (
+		buckets []uint64
+		from    uint64 = 1
+	)
+
+	for {
+		values, err := r.callReplicaset(ctx, uuid, "vshard.storage.buckets_discovery",
+			[]interface{}{map[string]interface{}{"from": from}})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(values) == 0 {
+			return nil, errors.New("vshard: buckets discovery returned nothing")
+		}
+
+		var page interface{}
+
+		switch v := values[0].(type) {
+		case []interface{}:
+			page = v
+		case map[interface{}]interface{}:
+			page = v["buckets"]
+		default:
+			return nil, errors.Errorf("vshard: buckets discovery returned %T", values[0])
+		}
+
+		if ids, ok := page.([]interface{}); ok {
+			for _, id := range ids {
+				n, ok := toUint(id)
+				if !ok {
+					return nil, errors.Errorf("vshard: invalid bucket id %v", id)
+				}
+
+				buckets = append(buckets, n)
+			}
+		}
+
+		result, ok := values[0].(map[interface{}]interface{})
+		if !ok {
+			return buckets, nil
+		}
+
+		if from, ok = toUint(result["next_from"]); !ok {
+			return buckets, nil
+		}
+	}
+}
+
+// Route returns uuid of replicaset which stores bucket. Unknown bucket
+// is searched on all replicasets.
+func (r *Router) Route(ctx context.Context, bucketID uint64) (string, error) {
+	if bucketID == 0 || bucketID > r.opts.BucketCount {
+		return "", errors.Errorf("vshard: invalid bucket id %d", bucketID)
+	}
+
+	r.mutex.RLock()
+	uuid := r.routes[bucketID]
+	r.mutex.RUnlock()
+
+	if uuid != "" {
+		return uuid, nil
+	}
+
+	var err error
+
+	for _, uuid := range r.uuids {
+		values, serr := r.callReplicaset(ctx, uuid, "vshard.storage.bucket_stat", []interface{}{bucketID})
+		if serr != nil {
+			if ctx.Err() != nil {
+				return "", serr
+			}
+
+			if err == nil {
+				err = serr
+			}
+
+			continue
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		// bucket which is not stored by replicaset is returned as nil and error
+		stat, ok := values[0].(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+
+		if status := stat["status"]; status == "active" || status == "pinned" {
+			r.setRoute(bucketID, uuid)
+
+			return uuid, nil
+		}
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return "", &Error{
+		Type:     "ShardingError",
+		Code:     ErrNoRouteToBucket,
+		Name:     "NO_ROUTE_TO_BUCKET",
+		Message:  fmt.Sprintf("Bucket %d cannot be found", bucketID),
+		BucketID: bucketID,
+	}
+}
+
+func (r *Router) setRoute(bucketID uint64, uuid string) {
+	r.mutex.Lock()
+	r.routes[bucketID] = uuid
+	r.mutex.Unlock()
+}
+
+// CallRO calls function for reading data of bucket.
+func (r *Router) CallRO(ctx context.Context, bucketID uint64, function string, args []interface{}) ([]interface{}, error) {
+	return r.call(ctx, bucketID, "read", function, args)
+}
+
+// CallRW calls function for writing data of bucket.
+func (r *Router) CallRW(ctx context.Context, bucketID uint64, function string, args []interface{}) ([]interface{}, error) {
+	return r.call(ctx, bucketID, "write", function, args)
+}
+
+// call calls function on storage of bucket and retries it if bucket
+// is moved.
+func (r *Router) call(ctx context.Context, bucketID uint64, mode, function string, args []interface{}) ([]interface{}, error) {
+	if args == nil {
+		args = []interface{}{}
+	}
+
+	for attempt := 0; ; attempt++ {
+		values, err := r.callBucket(ctx, bucketID, mode, function, args)
+
+		var vErr *Error
+		if err == nil || !errors.As(err, &vErr) || attempt >= r.opts.MaxRetries {
+			return values, err
+		}
+
+		switch vErr.Code {
+		case ErrWrongBucket:
+			if _, ok := r.replicasets[vErr.Destination]; ok {
+				r.opts.Logger.Debug("vshard: bucket is moved", "bucket", bucketID, "replicaset", vErr.Destination)
+				r.setRoute(bucketID, vErr.Destination)
+
+				continue
+			}
+
+			// destination is unknown, bucket is searched again
+			r.setRoute(bucketID, "")
+		case ErrTransferIsInProgress, ErrBucketIsLocked, ErrNoRouteToBucket:
+		default:
+			return nil, err
+		}
+
+		timer := time.NewTimer(r.opts.RetryInterval)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// callBucket calls function on storage of bucket once. Storage returns
+// true and results of function, or nil and error.
+func (r *Router) callBucket(ctx context.Context, bucketID uint64, mode, function string, args []interface{}) ([]interface{}, error) {
+	uuid, err := r.Route(ctx, bucketID)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := r.callReplicaset(ctx, uuid, "vshard.storage.call", []interface{}{bucketID, mode, function, args})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) > 0 && values[0] == true {
+		return values[1:], nil
+	}
+
+	if len(values) > 1 {
+		return nil, decodeError(values[1])
+	}
+
+	return nil, errors.Errorf("vshard: unexpected result of storage call %v", values)
+}
+
+func (r *Router) callReplicaset(ctx context.Context, uuid, function string, args []interface{}) ([]interface{}, error) {
+	b, err := msgp.AppendIntf(nil, args)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't encode arguments of %s", function)
+	}
+
+	resp, err := r.replicasets[uuid].Do(ctx, tarantool.NewCall17Request(function, msgp.Raw(b)))
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Release()
+
+	if resp.Code != tarantool.OkCode {
+		return nil, tarantool.Error{Code: resp.Code, Msg: resp.Error}
+	}
+
+	return resp.Values()
+}
+
+func toUint(v interface{}) (uint64, bool) {
+	switch v := v.(type) {
+	case uint64:
+		return v, true
+	case int64:
+		return uint64(v), v >= 0
+	case float64:
+		// lua numbers could be encoded as doubles
+		return uint64(v), v >= 0 && v == float64(uint64(v))
+	default:
+		return 0, false
+	}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
@@ -0,0 +1,333 @@
+package vshard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/tarantooltest"
+)
+
+const bucketCount = 10
+
+// fakeStorage emulates vshard storage on tarantooltest server.
+type fakeStorage struct {
+	uuid  string
+	mutex sync.Mutex
+	// buckets are statuses of stored buckets
+	buckets map[uint64]string
+	// moved are destinations of sent buckets
+	moved map[uint64]string
+	// paged storage returns buckets by pages as new versions of vshard
+	paged bool
+	// calls are calls of functions, ie "read get 3"
+	calls []string
+	// transfer receives calls of buckets which are being sent
+	transfer chan struct{}
+}
+
+func (st *fakeStorage) register(s *tarantooltest.Server) {
+	s.RegisterFunc("vshard.storage.buckets_discovery", func(args []interface{}) ([]interface{}, error) {
+		st.mutex.Lock()
+		defer st.mutex.Unlock()
+
+		ids := make([]interface{}, 0, len(st.buckets))
+
+		for id, status := range st.buckets {
+			if status == "active" {
+				ids = append(ids, id)
+			}
+		}
+
+		sort.Slice(ids, func(i, j int) bool { return ids[i].(uint64) < ids[j].(uint64) })
+
+		if !st.paged {
+			return []interface{}{ids}, nil
+		}
+
+		from, _ := toUint(args[0].(map[interface{}]interface{})["from"])
+		page := make([]interface{}, 0, 2)
+
+		for _, id := range ids {
+			if id.(uint64) >= from && len(page) < 2 {
+				page = append(page, id)
+			}
+		}
+
+		result := map[string]interface{}{"buckets": page}
+		if len(page) == 2 {
+			result["next_from"] = page[1].(uint64) + 1
+		}
+
+		return []interface{}{result}, nil
+	})
+
+	s.RegisterFunc("vshard.storage.bucket_stat", func(args []interface{}) ([]interface{}, error) {
+		st.mutex.Lock()
+		defer st.mutex.Unlock()
+
+		id, _ := toUint(args[0])
+
+		status, ok := st.buckets[id]
+		if !ok {
+			return []interface{}{nil, st.error(ErrWrongBucket, "WRONG_BUCKET", id)}, nil
+		}
+
+		return []interface{}{map[string]interface{}{"id": id, "status": status}}, nil
+	})
+
+	s.RegisterFunc("vshard.storage.call", func(args []interface{}) ([]interface{}, error) {
+		st.mutex.Lock()
+		defer st.mutex.Unlock()
+
+		id, _ := toUint(args[0])
+
+		switch st.buckets[id] {
+		case "active":
+		case "sending":
+			select {
+			case st.transfer <- struct{}{}:
+			default:
+			}
+
+			return []interface{}{nil, st.error(ErrTransferIsInProgress, "TRANSFER_IS_IN_PROGRESS", id)}, nil
+		default:
+			return []interface{}{nil, st.error(ErrWrongBucket, "WRONG_BUCKET", id)}, nil
+		}
+
+		st.calls = append(st.calls, fmt.Sprintf("%s %s %v", args[1], args[2], args[3]))
+
+		return []interface{}{true, st.uuid, args[3]}, nil
+	})
+}
+
+func (st *fakeStorage) error(code uint64, name string, id uint64) map[string]interface{} {
+	e := map[string]interface{}{
+		"type":      "ShardingError",
+		"code":      code,
+		"name":      name,
+		"message":   fmt.Sprintf("Cannot perform action with bucket %d", id),
+		"bucket_id": id,
+	}
+
+	if dest, ok := st.moved[id]; ok {
+		e["destination"] = dest
+	}
+
+	return e
+}
+
+func (st *fakeStorage) set(id uint64, status string) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	if status == "" {
+		delete(st.buckets, id)
+	} else {
+		st.buckets[id] = status
+	}
+}
+
+// move deletes bucket sent to replicaset dest.
+func (st *fakeStorage) move(id uint64, dest string) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	delete(st.buckets, id)
+	st.moved[id] = dest
+}
+
+func newStorage(t *testing.T, uuid string, paged bool, buckets ...uint64) (*tarantooltest.Server, *fakeStorage) {
+	s, err := tarantooltest.NewServer("")
+	if err != nil {
+		t.Fatalf("Failed to start server: %s", err.Error())
+	}
+
+	t.Cleanup(func() { s.Close() })
+
+	st := &fakeStorage{
+		uuid:     uuid,
+		buckets:  make(map[uint64]string),
+		moved:    make(map[uint64]string),
+		paged:    paged,
+		transfer: make(chan struct{}, 1),
+	}
+
+	for _, id := range buckets {
+		st.buckets[id] = "active"
+	}
+
+	st.register(s)
+
+	return s, st
+}
+
+func newRouter(t *testing.T) (*Router, *fakeStorage, *fakeStorage) {
+	s1, st1 := newStorage(t, "aaaaaaaa-0000-4000-8000-000000000001", true, 1, 2, 3, 4, 5)
+	s2, st2 := newStorage(t, "aaaaaaaa-0000-4000-8000-000000000002", false, 6, 7, 8, 9, 10)
+
+	r, err := New(Opts{
+		Replicasets: map[string]string{
+			st1.uuid: s1.Addr(),
+			st2.uuid: s2.Addr(),
+		},
+		BucketCount:   bucketCount,
+		RetryInterval: time.Millisecond,
+		MaxRetries:    20,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create router: %s", err.Error())
+	}
+
+	t.Cleanup(func() { r.Close() })
+
+	return r, st1, st2
+}
+
+func TestBucketID(t *testing.T) {
+	// check value of crc32c without final inversion
+	if crc := crc32c([]byte("123456789")); crc != 0x1cf96d7c {
+		t.Errorf("Unexpected crc32c 0x%08x", crc)
+	}
+
+	id := func(key interface{}) uint64 {
+		id, err := BucketID(key, 3000)
+		if err != nil {
+			t.Fatalf("Failed to compute bucket id of %v: %s", key, err.Error())
+		}
+
+		if id < 1 || id > 3000 {
+			t.Errorf("Bucket id %d of %v is out of range", id, key)
+		}
+
+		return id
+	}
+
+	// strings are hashed as is, numbers are encoded as lua encodes them
+	if id("123456789") != 0x1cf96d7c%3000+1 {
+		t.Errorf("Unexpected bucket id %d of string", id("123456789"))
+	}
+
+	if id(int64(200)) != id(uint8(200)) || id(200) != id(200.0) || id(-5) != id(int8(-5)) || id(-5) != id(-5.0) {
+		t.Errorf("Bucket ids of the same numbers differ")
+	}
+
+	if crc := crc32c([]byte{0xcc, 200}); id(200) != uint64(crc)%3000+1 {
+		t.Errorf("Unexpected bucket id %d of 200", id(200))
+	}
+
+	if id([]interface{}{"user", 42}) != id(append([]byte("user"), 42)) || id([]string{"a", "b"}) != id("ab") {
+		t.Errorf("Bucket id of composite key is not bucket id of its parts")
+	}
+
+	if _, err := BucketID(struct{}{}, 3000); err == nil {
+		t.Errorf("Bucket id of struct is computed")
+	}
+}
+
+func TestRouter(t *testing.T) {
+	r, st1, st2 := newRouter(t)
+	ctx := context.Background()
+
+	if err := r.Discover(ctx); err != nil {
+		t.Fatalf("Failed to discover buckets: %s", err.Error())
+	}
+
+	for id := uint64(1); id <= bucketCount; id++ {
+		expected := st1.uuid
+		if id > 5 {
+			expected = st2.uuid
+		}
+
+		if uuid, err := r.Route(ctx, id); err != nil || uuid != expected {
+			t.Errorf("Unexpected route %s of bucket %d: %v", uuid, id, err)
+		}
+	}
+
+	values, err := r.CallRO(ctx, 3, "get", []interface{}{"key"})
+	if expected := []interface{}{st1.uuid, []interface{}{"key"}}; err != nil || !reflect.DeepEqual(values, expected) {
+		t.Errorf("Unexpected result %v: %v", values, err)
+	}
+
+	if _, err = r.CallRW(ctx, 8, "put", []interface{}{"key", 1}); err != nil {
+		t.Errorf("Failed to call: %s", err.Error())
+	}
+
+	if fmt.Sprint(st1.calls, st2.calls) != "[read get [key]] [write put [key 1]]" {
+		t.Errorf("Unexpected calls %v and %v", st1.calls, st2.calls)
+	}
+
+	if _, err = r.Route(ctx, bucketCount+1); err == nil {
+		t.Errorf("Bucket out of range is routed")
+	}
+}
+
+func TestRouterRebalancing(t *testing.T) {
+	r, st1, st2 := newRouter(t)
+	ctx := context.Background()
+
+	if err := r.Discover(ctx); err != nil {
+		t.Fatalf("Failed to discover buckets: %s", err.Error())
+	}
+
+	// bucket is moved with known destination
+	st2.set(1, "active")
+	st1.move(1, st2.uuid)
+
+	if values, err := r.CallRW(ctx, 1, "put", nil); err != nil || values[0] != st2.uuid {
+		t.Errorf("Unexpected result %v of moved bucket: %v", values, err)
+	}
+
+	// bucket is moved without destination, it is searched on replicasets
+	st1.set(2, "")
+	st2.set(2, "active")
+
+	if values, err := r.CallRO(ctx, 2, "get", nil); err != nil || values[0] != st2.uuid {
+		t.Errorf("Unexpected result %v of moved bucket: %v", values, err)
+	}
+
+	// call waits for the end of transfer
+	st1.set(3, "sending")
+
+	go func() {
+		<-st1.transfer
+		st2.set(3, "active")
+		st1.move(3, st2.uuid)
+	}()
+
+	if values, err := r.CallRW(ctx, 3, "put", nil); err != nil || values[0] != st2.uuid {
+		t.Errorf("Unexpected result %v of transferred bucket: %v", values, err)
+	}
+
+	// bucket is lost
+	st1.set(4, "")
+
+	_, err := r.CallRW(ctx, 4, "put", nil)
+	if vErr := (*Error)(nil); !errors.As(err, &vErr) || vErr.Code != ErrNoRouteToBucket {
+		t.Errorf("Unexpected error of lost bucket: %v", err)
+	}
+
+	// discovery forgets moved buckets and finds new ones
+	if err = r.Discover(ctx); err != nil {
+		t.Fatalf("Failed to discover buckets: %s", err.Error())
+	}
+
+	st2.set(5, "active")
+	st1.set(5, "")
+
+	r.Discover(ctx)
+
+	r.mutex.RLock()
+	routes := fmt.Sprint(r.routes[1:])
+	r.mutex.RUnlock()
+
+	if expected := fmt.Sprint([]string{st2.uuid, st2.uuid, st2.uuid, "", st2.uuid,
+		st2.uuid, st2.uuid, st2.uuid, st2.uuid, st2.uuid}); routes != expected {
+		t.Errorf("Unexpected routes %s", routes)
+	}
+}
@@ -0,0 +1,390 @@
+// Package xlog reads tarantool xlog and snapshot files, ie to analyze
+// incidents or to export data from backups without running tarantool.
+// Rows are decoded to the same structure as rows of replication stream:
+//
+//	r, err := xlog.Open("00000000000000000042.xlog", xlog.Opts{})
+//	defer r.Close()
+//
+//	for {
+//		row, err := r.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//
+//		// row.Type, row.Space, row.Tuple...
+//	}
+//
+// Tarantool compresses blocks of rows with zstd if they are large enough
+// (xlog files written by default settings have such blocks), so files should
+// be read with decompressor. The package doesn't depend on zstd
+// implementation, decoder is passed in options. Separate module
+// gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/xlog/zstd passes
+// github.com/klauspost/compress/zstd one:
+//
+//	r, err := zstd.Open(name)
+//
+// Without it reading stops with ErrNoDecompressor at the first compressed
+// block.
+package xlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+	"gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/replication"
+)
+
+// Markers of blocks.
+const (
+	rowMarker  = 0xd5ba0bab
+	zrowMarker = 0xd5ba0bba
+	eofMarker  = 0xd510aded
+)
+
+// fixHeaderSize is size of block header: marker, length, previous
+// and current checksums, padded to the same size.
+const fixHeaderSize = 19
+
+var (
+	// ErrChecksum is returned if checksum of block doesn't match its data.
+	ErrChecksum = errors.New("xlog: checksum mismatch")
+	// ErrTruncated is returned if file ends in the middle of block.
+	ErrTruncated = errors.New("xlog: file is truncated")
+	// ErrNoDecompressor is returned if block is compressed, but Opts.Zstd
+	// is not set.
+	ErrNoDecompressor = errors.New("xlog: block is compressed with zstd, but Opts.Zstd decompressor is not set")
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Decompressor decompresses zstd frame, *zstd.Decoder of
+// github.com/klauspost/compress/zstd implements it.
+type Decompressor interface {
+	DecodeAll(input, dst []byte) ([]byte, error)
+}
+
+// Opts are options of reader.
+type Opts struct {
+	// Zstd decompresses compressed blocks. Reading of compressed
+	// block fails with ErrNoDecompressor if it is nil.
+	Zstd Decompressor
+}
+
+// Meta is text header of file.
+type Meta struct {
+	// Type is type of file, ie "XLOG" or "SNAP".
+	Type string
+	// Format is version of file format, ie "0.13".
+	Format string
+	// Version is version of tarantool which has written file.
+	Version string
+	// Instance is uuid of instance which has written file.
+	Instance tarantool.UUID
+	// Vclock is vclock of the first row of xlog, or of snapshot.
+	Vclock replication.Vclock
+	// PrevVclock is vclock of previous xlog, it is nil if it is not known.
+	PrevVclock replication.Vclock
+	// Headers contains all keys of header as is.
+	Headers map[string]string
+}
+
+// Reader reads rows of file.
+type Reader struct {
+	Meta Meta
+
+	opts   Opts
+	r      *bufio.Reader
+	closer io.Closer
+	offset int64
+
+	// block is data of current block, rows are read from it
+	block []byte
+	done  bool
+}
+
+// Open opens file and reads its meta.
+func Open(name string, opts Opts) (*Reader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewReader(f, opts)
+	if err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	r.closer = f
+
+	return r, nil
+}
+
+// NewReader reads meta of file from r.
+func NewReader(r io.Reader, opts Opts) (*Reader, error) {
+	xr := &Reader{
+		opts: opts,
+		r:    bufio.NewReader(r),
+	}
+
+	if err := xr.readMeta(); err != nil {
+		return nil, err
+	}
+
+	return xr, nil
+}
+
+// Close closes file opened by Open.
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+
+	return r.closer.Close()
+}
+
+func (r *Reader) readLine() (string, error) {
+	line, err := r.r.ReadString('\n')
+	r.offset += int64(len(line))
+
+	if err == io.EOF {
+		return "", ErrTruncated
+	} else if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+func (r *Reader) readMeta() (err error) {
+	meta := &r.Meta
+	meta.Headers = make(map[string]string)
+
+	if meta.Type, err = r.readLine(); err != nil {
+		return errors.Wrap(err, "can't read file type")
+	}
+
+	if meta.Format, err = r.readLine(); err != nil {
+		return errors.Wrap(err, "can't read format version")
+	}
+
+	if meta.Format != "0.12" && meta.Format != "0.13" {
+		return errors.Errorf("xlog: unsupported format version %q", meta.Format)
+	}
+
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return errors.Wrap(err, "can't read meta")
+		}
+
+		if line == "" {
+			break
+		}
+
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return errors.Errorf("xlog: invalid meta line %q", line)
+		}
+
+		key, value := line[:i], strings.TrimSpace(line[i+1:])
+		meta.Headers[key] = value
+
+		switch key {
+		case "Version":
+			meta.Version = value
+		case "Instance", "Server":
+			if meta.Instance, err = tarantool.ParseUUID(value); err != nil {
+				return errors.Wrapf(err, "xlog: invalid %s", key)
+			}
+		case "VClock":
+			if meta.Vclock, err = parseVclock(value); err != nil {
+				return err
+			}
+		case "PrevVClock":
+			if meta.PrevVclock, err = parseVclock(value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseVclock parses vclock formatted as "{1: 10, 2: 5}".
+func parseVclock(s string) (replication.Vclock, error) {
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return nil, errors.Errorf("xlog: invalid vclock %q", s)
+	}
+
+	vclock := replication.Vclock{}
+
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	if s == "" {
+		return vclock, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("xlog: invalid vclock %q", s)
+		}
+
+		id, err := strconv.ParseUint(strings.TrimSpace(kv[0]), 10, 32)
+		if err != nil {
+			return nil, errors.Errorf("xlog: invalid vclock %q", s)
+		}
+
+		lsn, err := strconv.ParseUint(strings.TrimSpace(kv[1]), 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("xlog: invalid vclock %q", s)
+		}
+
+		vclock[uint32(id)] = lsn
+	}
+
+	return vclock, nil
+}
+
+// Next returns next row, or io.EOF after the last one. File of running
+// tarantool could end without end marker, it is io.EOF as well.
+func (r *Reader) Next() (*replication.Row, error) {
+	for len(r.block) == 0 {
+		if r.done {
+			return nil, io.EOF
+		}
+
+		if err := r.readBlock(); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := msgp.Skip(r.block)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decode row header")
+	}
+
+	end := body
+
+	// nop has no body, and the next row follows its header
+	if len(body) > 0 && rowType(r.block[:len(r.block)-len(body)]) != tarantool.NopRequest {
+		if end, err = msgp.Skip(body); err != nil {
+			return nil, errors.Wrap(err, "can't decode row body")
+		}
+	}
+
+	packet := r.block[:len(r.block)-len(end)]
+	r.block = end
+
+	return replication.DecodeRow(packet)
+}
+
+// rowType returns type of row header.
+func rowType(header []byte) int32 {
+	n, b, err := msgp.ReadMapHeaderBytes(header)
+
+	for ; err == nil && n > 0; n-- {
+		var key, value uint64
+
+		if key, b, err = msgp.ReadUint64Bytes(b); err != nil {
+			break
+		}
+
+		if key != tarantool.KeyCode {
+			b, err = msgp.Skip(b)
+
+			continue
+		}
+
+		if value, _, err = msgp.ReadUint64Bytes(b); err == nil {
+			return int32(value)
+		}
+	}
+
+	return -1
+}
+
+// readBlock reads block, checks its checksum and decompresses it.
+func (r *Reader) readBlock() error {
+	var fixHeader [fixHeaderSize]byte
+
+	n, err := io.ReadFull(r.r, fixHeader[:4])
+	if err == io.EOF {
+		r.done = true
+
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(ErrTruncated, "block at %d", r.offset)
+	}
+
+	marker := binary.BigEndian.Uint32(fixHeader[:4])
+	if marker == eofMarker {
+		r.done = true
+
+		return nil
+	}
+
+	if marker != rowMarker && marker != zrowMarker {
+		return errors.Errorf("xlog: invalid marker 0x%08x of block at %d", marker, r.offset)
+	}
+
+	if _, err = io.ReadFull(r.r, fixHeader[n:]); err != nil {
+		return errors.Wrapf(ErrTruncated, "block at %d", r.offset)
+	}
+
+	length, b, err := msgp.ReadUint32Bytes(fixHeader[4:])
+	if err == nil {
+		// checksum of previous block is not used
+		_, b, err = msgp.ReadUint32Bytes(b)
+	}
+
+	var checksum uint32
+
+	if err == nil {
+		checksum, _, err = msgp.ReadUint32Bytes(b)
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "can't decode header of block at %d", r.offset)
+	}
+
+	data := make([]byte, length)
+
+	if _, err = io.ReadFull(r.r, data); err != nil {
+		return errors.Wrapf(ErrTruncated, "block at %d", r.offset)
+	}
+
+	if crc32c(data) != checksum {
+		return errors.Wrapf(ErrChecksum, "block at %d", r.offset)
+	}
+
+	if marker == zrowMarker {
+		if r.opts.Zstd == nil {
+			return errors.Wrapf(ErrNoDecompressor, "block at %d", r.offset)
+		}
+
+		if data, err = r.opts.Zstd.DecodeAll(data, nil); err != nil {
+			return errors.Wrapf(err, "can't decompress block at %d", r.offset)
+		}
+	}
+
+	r.offset += fixHeaderSize + int64(length)
+	r.block = data
+
+	return nil
+}
+
+// crc32c returns checksum of data as tarantool calculates it:
+// crc32c without initial and final inversion.
+func crc32c(data []byte) uint32 {
+	return ^crc32.Update(^uint32(0), castagnoli, data)
+}
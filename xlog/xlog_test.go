@@ -0,0 +1,187 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/pkg/errors"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+	"gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/server"
+)
+
+// reversed is fake decompressor: compressed data is data in reverse order.
+type reversed struct{}
+
+func (reversed) DecodeAll(input, dst []byte) ([]byte, error) {
+	for i := len(input) - 1; i >= 0; i-- {
+		dst = append(dst, input[i])
+	}
+
+	return dst, nil
+}
+
+func reverse(b []byte) []byte {
+	r, _ := reversed{}.DecodeAll(b, nil)
+
+	return r
+}
+
+// appendMap appends map of key-value pairs.
+func appendMap(b []byte, pairs ...interface{}) []byte {
+	b = msgp.AppendMapHeader(b, uint32(len(pairs)/2))
+
+	for _, v := range pairs {
+		var err error
+
+		if b, err = msgp.AppendIntf(b, v); err != nil {
+			panic(err)
+		}
+	}
+
+	return b
+}
+
+func appendBlock(b []byte, marker uint32, data []byte) []byte {
+	start := len(b)
+
+	b = appendMarker(b, marker)
+	b = msgp.AppendUint32(b, uint32(len(data)))
+	b = msgp.AppendUint32(b, 0)
+	b = msgp.AppendUint32(b, crc32c(data))
+
+	// padding is string of zeros
+	padding := fixHeaderSize - (len(b) - start)
+	b = append(b, 0xa0|byte(padding-1))
+	b = append(b, make([]byte, padding-1)...)
+
+	return append(b, data...)
+}
+
+func testFile() []byte {
+	b := []byte("XLOG\n0.13\nVersion: 2.11.1-0-g96877bd35\n" +
+		"Instance: 7f8c3b9e-6a54-4c4b-9d3e-2b1a0f9e8d7c\nVClock: {1: 10, 2: 3}\nPrevVClock: {}\n\n")
+
+	var rows []byte
+
+	rows = appendMap(rows, tarantool.KeyCode, tarantool.InsertRequest, server.KeyReplicaID, 1,
+		server.KeyLSN, 11, server.KeyTimestamp, 1672628645.5)
+	rows = appendMap(rows, tarantool.KeySpaceNo, 512, tarantool.KeyTuple, []interface{}{1, "one"})
+	// nop has no body
+	rows = appendMap(rows, tarantool.KeyCode, tarantool.NopRequest, server.KeyReplicaID, 1, server.KeyLSN, 12)
+	rows = appendMap(rows, tarantool.KeyCode, tarantool.UpdateRequest, server.KeyReplicaID, 2,
+		server.KeyLSN, 4, server.KeyTSN, 0)
+	rows = appendMap(rows, tarantool.KeySpaceNo, 512, tarantool.KeyKey, []interface{}{1},
+		tarantool.KeyTuple, []interface{}{[]interface{}{"=", 1, "uno"}})
+
+	b = appendBlock(b, rowMarker, rows)
+
+	rows = appendMap(nil, tarantool.KeyCode, tarantool.DeleteRequest, server.KeyReplicaID, 2,
+		server.KeyLSN, 5, server.KeyTSN, 1, server.KeyFlags, server.FlagCommit)
+	rows = appendMap(rows, tarantool.KeySpaceNo, 512, tarantool.KeyKey, []interface{}{1})
+
+	b = appendBlock(b, zrowMarker, reverse(rows))
+
+	return appendMarker(b, eofMarker)
+}
+
+func appendMarker(b []byte, marker uint32) []byte {
+	var m [4]byte
+
+	binary.BigEndian.PutUint32(m[:], marker)
+
+	return append(b, m[:]...)
+}
+
+func readAll(r *Reader) ([]string, error) {
+	var rows []string
+
+	for {
+		row, err := r.Next()
+		if err != nil {
+			return rows, err
+		}
+
+		values, err := row.Values()
+		if err != nil {
+			return rows, err
+		}
+
+		rows = append(rows, fmt.Sprintf("%s tsn %d commit %v %v", row, row.TSN, row.Commit, values))
+	}
+}
+
+func TestReader(t *testing.T) {
+	file := testFile()
+
+	r, err := NewReader(bytes.NewReader(file), Opts{Zstd: reversed{}})
+	if err != nil {
+		t.Fatalf("Failed to read meta: %s", err.Error())
+	}
+
+	meta := r.Meta
+	if meta.Type != "XLOG" || meta.Version != "2.11.1-0-g96877bd35" ||
+		meta.Instance.String() != "7f8c3b9e-6a54-4c4b-9d3e-2b1a0f9e8d7c" ||
+		meta.Vclock.String() != "{1: 10, 2: 3}" || meta.PrevVclock == nil || len(meta.PrevVclock) != 0 {
+		t.Errorf("Unexpected meta %+v", meta)
+	}
+
+	rows, err := readAll(r)
+	if err != io.EOF {
+		t.Fatalf("Failed to read rows: %v", err)
+	}
+
+	expected := []string{
+		"insert space 512 lsn 1:11 tsn 11 commit true [1 one]",
+		"nop lsn 1:12 tsn 12 commit true []",
+		"update space 512 lsn 2:4 tsn 4 commit false [1]",
+		"delete space 512 lsn 2:5 tsn 4 commit true [1]",
+	}
+
+	if fmt.Sprint(rows) != fmt.Sprint(expected) {
+		t.Errorf("Unexpected rows:\n%v\nexpected:\n%v", rows, expected)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	file := testFile()
+	eofSize := 4
+
+	cases := []struct {
+		name  string
+		file  []byte
+		opts  Opts
+		rows  int
+		check func(err error) bool
+	}{
+		{"without eof marker", file[:len(file)-eofSize], Opts{Zstd: reversed{}}, 4,
+			func(err error) bool { return err == io.EOF }},
+		{"truncated", file[:len(file)-eofSize-1], Opts{Zstd: reversed{}}, 3,
+			func(err error) bool { return errors.Is(err, ErrTruncated) }},
+		{"without zstd", file, Opts{}, 3,
+			func(err error) bool { return errors.Is(err, ErrNoDecompressor) }},
+		{"checksum", append(append([]byte{}, file[:len(file)-eofSize-1]...), 0, 0, 0, 0, 0), Opts{Zstd: reversed{}}, 3,
+			func(err error) bool { return errors.Is(err, ErrChecksum) }},
+		{"meta", file[:20], Opts{}, 0,
+			func(err error) bool { return errors.Is(err, ErrTruncated) }},
+	}
+
+	for _, c := range cases {
+		r, err := NewReader(bytes.NewReader(c.file), c.opts)
+		if err != nil {
+			if c.rows != 0 || !c.check(err) {
+				t.Errorf("%s: unexpected error of meta: %v", c.name, err)
+			}
+
+			continue
+		}
+
+		rows, err := readAll(r)
+		if len(rows) != c.rows || !c.check(err) {
+			t.Errorf("%s: unexpected %d rows and error %v", c.name, len(rows), err)
+		}
+	}
+}
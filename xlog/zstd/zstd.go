@@ -0,0 +1,33 @@
+// Package zstd reads xlog and snapshot files with zstd decompressor of
+// github.com/klauspost/compress/zstd. It is separate module, so xlog
+// itself doesn't depend on zstd implementation:
+//
+//	r, err := zstd.Open("00000000000000000042.xlog")
+//	defer r.Close()
+package zstd
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/xlog"
+)
+
+// decoder is shared by readers, DecodeAll could be called concurrently.
+// Creation without options doesn't fail.
+var decoder, _ = zstd.NewReader(nil)
+
+// Decompressor returns zstd decompressor for xlog.Opts.
+func Decompressor() xlog.Decompressor {
+	return decoder
+}
+
+// Open opens xlog or snapshot file and reads its meta.
+func Open(name string) (*xlog.Reader, error) {
+	return xlog.Open(name, xlog.Opts{Zstd: decoder})
+}
+
+// NewReader reads meta of xlog or snapshot from r.
+func NewReader(r io.Reader) (*xlog.Reader, error) {
+	return xlog.NewReader(r, xlog.Opts{Zstd: decoder})
+}
@@ -0,0 +1,140 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoWebProd/msgp/msgp"
+	"github.com/klauspost/compress/zstd"
+	tarantool "gitlab.corp.mail.ru/icqweb/go/go-tarantool.git"
+	"gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/server"
+	"gitlab.corp.mail.ru/icqweb/go/go-tarantool.git/xlog"
+)
+
+// Markers of blocks as tarantool writes them.
+const (
+	zrowMarker = 0xd5ba0bba
+	eofMarker  = 0xd510aded
+)
+
+const rows = 200
+
+func appendUint32(b []byte, v uint32) []byte {
+	var m [4]byte
+
+	binary.BigEndian.PutUint32(m[:], v)
+
+	return append(b, m[:]...)
+}
+
+// testFile returns xlog with rows compressed into one block, as tarantool
+// compresses blocks larger than 2 KiB.
+func testFile(t *testing.T) []byte {
+	var data []byte
+
+	for i := 0; i < rows; i++ {
+		data = msgp.AppendMapHeader(data, 3)
+		data = msgp.AppendUint(data, tarantool.KeyCode)
+		data = msgp.AppendUint(data, tarantool.InsertRequest)
+		data = msgp.AppendUint(data, server.KeyReplicaID)
+		data = msgp.AppendUint(data, 1)
+		data = msgp.AppendUint(data, server.KeyLSN)
+		data = msgp.AppendInt(data, i+1)
+
+		data = msgp.AppendMapHeader(data, 2)
+		data = msgp.AppendUint(data, tarantool.KeySpaceNo)
+		data = msgp.AppendUint(data, 512)
+		data = msgp.AppendUint(data, tarantool.KeyTuple)
+		data = msgp.AppendArrayHeader(data, 2)
+		data = msgp.AppendInt(data, i)
+		data = msgp.AppendString(data, fmt.Sprintf("user %d", i))
+	}
+
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %s", err.Error())
+	}
+
+	compressed := enc.EncodeAll(data, nil)
+	enc.Close()
+
+	b := []byte("XLOG\n0.13\nVersion: 2.11.1-0-g96877bd35\n" +
+		"Instance: 7f8c3b9e-6a54-4c4b-9d3e-2b1a0f9e8d7c\nVClock: {1: 0}\nPrevVClock: {}\n\n")
+
+	// header is marker, length, previous and current checksums,
+	// padded with string of zeros to 19 bytes
+	start := len(b)
+	b = appendUint32(b, zrowMarker)
+	b = msgp.AppendUint32(b, uint32(len(compressed)))
+	b = msgp.AppendUint32(b, 0)
+	// crc32c without initial and final inversion
+	b = msgp.AppendUint32(b, ^crc32.Update(^uint32(0), crc32.MakeTable(crc32.Castagnoli), compressed))
+
+	padding := 19 - (len(b) - start)
+	b = append(b, 0xa0|byte(padding-1))
+	b = append(b, make([]byte, padding-1)...)
+	b = append(b, compressed...)
+
+	return appendUint32(b, eofMarker)
+}
+
+func TestOpen(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "00000000000000000000.xlog")
+
+	if err := os.WriteFile(name, testFile(t), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %s", err.Error())
+	}
+
+	r, err := Open(name)
+	if err != nil {
+		t.Fatalf("Failed to open file: %s", err.Error())
+	}
+
+	defer r.Close()
+
+	for i := 0; ; i++ {
+		row, err := r.Next()
+		if err == io.EOF {
+			if i != rows {
+				t.Fatalf("Read %d rows, expected %d", i, rows)
+			}
+
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to read row %d: %s", i, err.Error())
+		}
+
+		values, err := row.Values()
+		if err != nil {
+			t.Fatalf("Failed to decode row %d: %s", i, err.Error())
+		}
+
+		if s, expected := fmt.Sprint(row, values), fmt.Sprintf("insert space 512 lsn 1:%d [%d user %d]", i+1, i, i); s != expected {
+			t.Fatalf("Unexpected row %s, expected %s", s, expected)
+		}
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("Failed to open file: %s", err.Error())
+	}
+
+	defer f.Close()
+
+	xr, err := xlog.NewReader(f, xlog.Opts{})
+	if err != nil {
+		t.Fatalf("Failed to read meta: %s", err.Error())
+	}
+
+	if _, err = xr.Next(); !errors.Is(err, xlog.ErrNoDecompressor) {
+		t.Errorf("Expected ErrNoDecompressor without decompressor, got %v", err)
+	}
+}